@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"ortho_vision_api/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidDeviceKey — API-ключ не належить жодному активному пристрою.
+var ErrInvalidDeviceKey = errors.New("invalid device api key")
+
+// ErrPatientDeactivated — пацієнта, до якого прив'язано пристрій, деактивовано.
+var ErrPatientDeactivated = errors.New("patient account is deactivated")
+
+// GenerateDeviceAPIKey створює API-ключ пристрою. Ключ показується лише один раз, у базі зберігається хеш.
+func GenerateDeviceAPIKey() (string, error) {
+	return GenerateRandomToken()
+}
+
+// GeneratePairingCode створює короткий код прив'язки вигляду "ABCDE-FGHIJ",
+// який пацієнт вводить вручну.
+func GeneratePairingCode() (string, error) {
+	return generateRecoveryCode()
+}
+
+// HashPairingCode повертає хеш коду прив'язки незалежно від регістру і дефіса.
+func HashPairingCode(code string) string {
+	return HashToken(normalizeRecoveryCode(code))
+}
+
+// AuthenticateDevice знаходить активний пристрій за API-ключем.
+func AuthenticateDevice(db *gorm.DB, apiKey string) (*models.SmartGlassesDevice, error) {
+	if apiKey == "" {
+		return nil, ErrInvalidDeviceKey
+	}
+	var device models.SmartGlassesDevice
+	result := db.Where("api_key_hash = ? AND disabled_at IS NULL", HashToken(apiKey)).Limit(1).Find(&device)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidDeviceKey
+	}
+	return &device, nil
+}
+
+// CheckDevicePatient перевіряє, що прив'язаний пристрій належить активному пацієнту.
+// Показники пристроїв деактивованих (або видалених) пацієнтів не приймаються: повертається ErrPatientDeactivated.
+func CheckDevicePatient(db *gorm.DB, device *models.SmartGlassesDevice) error {
+	var active int64
+	if err := db.Model(&models.User{}).
+		Where("id = ? AND deactivated_at IS NULL", device.PatientID).
+		Count(&active).Error; err != nil {
+		return err
+	}
+	if active == 0 {
+		return ErrPatientDeactivated
+	}
+	return nil
+}
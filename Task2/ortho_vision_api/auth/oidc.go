@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ErrOIDCNotConfigured — вхід через OIDC не налаштовано.
+var ErrOIDCNotConfigured = errors.New("oidc sign-in is not configured")
+
+// OIDCIdentity — перевірені дані користувача з ID-токена провайдера.
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCClient виконує authorization code flow з PKCE.
+// Конфігурація провайдера (discovery) завантажується під час першого запиту,
+// щоб сервер запускався навіть тоді, коли провайдер тимчасово недоступний.
+type OIDCClient struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewOIDCClient створює OIDCClient. Якщо clientID порожній, повертає nil.
+func NewOIDCClient(issuer, clientID, clientSecret, redirectURL string) *OIDCClient {
+	if clientID == "" {
+		return nil
+	}
+	return &OIDCClient{
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+	}
+}
+
+// AuthCodeURL повертає адресу, на яку потрібно перенаправити користувача для входу.
+func (o *OIDCClient) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	cfg, _, err := o.config()
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange обмінює код авторизації на токени та перевіряє ID-токен і nonce.
+func (o *OIDCClient) Exchange(ctx context.Context, code, nonce, codeVerifier string) (*OIDCIdentity, error) {
+	cfg, verifier, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("id_token missing in token response")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("parse id_token claims: %w", err)
+	}
+
+	return &OIDCIdentity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// config повертає налаштування OAuth2 і перевірник ID-токенів, за потреби виконуючи discovery.
+func (o *OIDCClient) config() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if o == nil {
+		return nil, nil, ErrOIDCNotConfigured
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.provider == nil {
+		// Контекст запиту не підходить: провайдер використовує його й для подальшого оновлення ключів
+		provider, err := oidc.NewProvider(context.Background(), o.issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc discovery for %s: %w", o.issuer, err)
+		}
+		o.provider = provider
+	}
+
+	cfg := &oauth2.Config{
+		ClientID:     o.clientID,
+		ClientSecret: o.clientSecret,
+		RedirectURL:  o.redirectURL,
+		Endpoint:     o.provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+	verifier := o.provider.Verifier(&oidc.Config{ClientID: o.clientID})
+	return cfg, verifier, nil
+}
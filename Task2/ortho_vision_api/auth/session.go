@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken — токен не знайдено або термін його дії минув.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused — повторне використання вже ротованого токена.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// TokenPair — пара токенів, яку отримує клієнт після входу або оновлення.
+type TokenPair struct {
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_expires_at"`
+	TokenType             string    `json:"token_type"`
+}
+
+// StartSession створює нову сесію пристрою та видає для неї пару токенів.
+func StartSession(db *gorm.DB, user models.User, device, ip string) (*TokenPair, error) {
+	now := time.Now()
+	return issueTokens(db, user, models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: uuid.NewString(),
+		Device:    device,
+		IP:        ip,
+		StartedAt: now,
+	})
+}
+
+// RotateSession перевіряє токен оновлення, відкликає його та видає нову пару токенів
+// у межах тієї ж сесії. Повторне використання відкликаного токена завершує всі сесії користувача.
+func RotateSession(db *gorm.DB, rawToken, device, ip string) (*TokenPair, error) {
+	var pair *TokenPair
+	var userID uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Preload("User").Where("token_hash = ?", HashToken(rawToken)).First(&current).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		// Токен уже був використаний — імовірно, його викрали
+		userID = current.UserID
+		if current.RevokedAt != nil {
+			return ErrRefreshTokenReused
+		}
+
+		if time.Now().After(current.ExpiresAt) || !current.User.IsActive() {
+			return ErrInvalidRefreshToken
+		}
+
+		// Відкликаємо поточний токен лише якщо його ще ніхто не встиг використати
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		pair, err = issueTokens(tx, current.User, models.RefreshToken{
+			UserID:    current.UserID,
+			SessionID: current.SessionID,
+			Device:    device,
+			IP:        ip,
+			StartedAt: current.StartedAt,
+		})
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		// Відкликаємо сесії поза транзакцією, щоб відкат їх не скасував
+		if revokeErr := RevokeAllSessions(db, userID); revokeErr != nil {
+			return nil, revokeErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// RevokeSession відкликає сесію користувача за її ID.
+// Повертає false, якщо активної сесії з таким ID немає.
+func RevokeSession(db *gorm.DB, userID uint, sessionID string) (bool, error) {
+	result := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id = ? AND revoked_at IS NULL", userID, sessionID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllSessions відкликає всі сесії користувача.
+func RevokeAllSessions(db *gorm.DB, userID uint) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeOtherSessions відкликає всі сесії користувача, крім сесії keepSessionID
+// (порожній keepSessionID — відкликати всі).
+func RevokeOtherSessions(db *gorm.DB, userID uint, keepSessionID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// ActiveSessions повертає всі активні сесії користувача.
+func ActiveSessions(db *gorm.DB, userID uint) ([]models.RefreshToken, error) {
+	var sessions []models.RefreshToken
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// IsSessionActive перевіряє, чи сесія не була відкликана і не закінчилася.
+func IsSessionActive(db *gorm.DB, userID uint, sessionID string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, sessionID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// HashToken повертає SHA-256 хеш токена у шістнадцятковому вигляді.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRandomToken створює випадковий токен для передачі клієнту.
+func GenerateRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// issueTokens зберігає новий токен оновлення для сесії та підписує токен доступу.
+func issueTokens(db *gorm.DB, user models.User, record models.RefreshToken) (*TokenPair, error) {
+	refreshToken, err := GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record.TokenHash = HashToken(refreshToken)
+	record.ExpiresAt = time.Now().Add(config.RefreshTokenTTL)
+	if err := db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	accessToken, accessExpiresAt, err := GenerateAccessToken(user, record.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: record.ExpiresAt,
+		TokenType:             "Bearer",
+	}, nil
+}
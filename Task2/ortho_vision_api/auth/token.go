@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Призначення токенів (claim "aud"), щоб токен одного типу не можна було використати замість іншого.
+const (
+	audienceAccess = "access"
+	audienceMFA    = "mfa"
+)
+
+// Claims — дані, які зберігаються в токені доступу.
+type Claims struct {
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// UserID повертає ID користувача з поля subject.
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid subject: %w", err)
+	}
+	return uint(id), nil
+}
+
+// GenerateAccessToken створює підписаний токен доступу для користувача в межах сесії.
+func GenerateAccessToken(user models.User, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(config.AccessTokenTTL)
+
+	claims := Claims{
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{audienceAccess},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(config.JWTSecret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// GenerateMFAToken створює короткочасний токен, який підтверджує, що пароль перевірено,
+// і дозволяє завершити вхід кодом TOTP.
+func GenerateMFAToken(user models.User) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{audienceMFA},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.MFATokenTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(config.JWTSecret)
+}
+
+// ParseAccessToken перевіряє підпис і термін дії токена доступу та повертає його дані.
+func ParseAccessToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, audienceAccess)
+}
+
+// ParseMFAToken перевіряє токен другого кроку входу та повертає його дані.
+func ParseMFAToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, audienceMFA)
+}
+
+func parseToken(tokenString, audience string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return config.JWTSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithAudience(audience))
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+// NewTOTPKey створює новий секрет TOTP для облікового запису.
+// Ключ містить otpauth:// URI, який застосунок-автентифікатор зчитує з QR-коду.
+func NewTOTPKey(accountName string) (*otp.Key, error) {
+	return totp.Generate(totp.GenerateOpts{
+		Issuer:      config.TOTPIssuer,
+		AccountName: accountName,
+	})
+}
+
+// totpPeriod — тривалість кроку TOTP у секундах (як у totp.Validate за замовчуванням).
+const totpPeriod = 30
+
+// UseTOTP перевіряє код TOTP (з допуском на один період у кожен бік) і запам'ятовує його крок часу.
+// Код того самого або попереднього кроку після цього не приймається, тому перехоплений код
+// не можна використати повторно. Повертає false, якщо код недійсний або вже використаний.
+func UseTOTP(db *gorm.DB, user *models.User, code string) (bool, error) {
+	step, ok := matchTOTPStep(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false, nil
+	}
+	// Умова в запиті не дає двом паралельним запитам прийняти один код
+	result := db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	return true, nil
+}
+
+// matchTOTPStep повертає крок часу, для якого дійсний код. Якщо код збігається з кількома
+// кроками, повертається найпізніший.
+func matchTOTPStep(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if secret == "" || code == "" {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current + 1; step >= current-1; step-- {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// ReplaceRecoveryCodes видаляє старі коди відновлення користувача і створює нові.
+// Коди повертаються у відкритому вигляді лише один раз, у базі зберігаються їхні хеші.
+func ReplaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, config.TOTPRecoveryCodeCount)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TOTPRecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		records := make([]models.TOTPRecoveryCode, 0, config.TOTPRecoveryCodeCount)
+		for i := 0; i < config.TOTPRecoveryCodeCount; i++ {
+			code, err := generateRecoveryCode()
+			if err != nil {
+				return err
+			}
+			codes = append(codes, code)
+			records = append(records, models.TOTPRecoveryCode{
+				UserID:   userID,
+				CodeHash: HashToken(normalizeRecoveryCode(code)),
+			})
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode позначає код відновлення використаним. Повертає false, якщо код недійсний.
+func UseRecoveryCode(db *gorm.DB, userID uint, code string) (bool, error) {
+	result := db.Model(&models.TOTPRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// IsTwoFactorRequired перевіряє, чи є двофакторна автентифікація обов'язковою для ролі.
+func IsTwoFactorRequired(db *gorm.DB, role string) (bool, error) {
+	var policy models.TwoFactorPolicy
+	result := db.Where("role = ?", role).Limit(1).Find(&policy)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return policy.Required, nil
+}
+
+// generateRecoveryCode створює код вигляду "ABCDE-FGHIJ".
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 7)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)[:10]
+	return raw[:5] + "-" + raw[5:], nil
+}
+
+// normalizeRecoveryCode дозволяє вводити код без дефіса і в будь-якому регістрі.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestMatchTOTPStep(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1_700_000_010, 0)
+	current := now.Unix() / totpPeriod
+
+	codeAt := func(step int64) string {
+		t.Helper()
+		code, err := totp.GenerateCode(secret, time.Unix(step*totpPeriod, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name string
+		code string
+		step int64
+		ok   bool
+	}{
+		{"current step", codeAt(current), current, true},
+		{"previous step", codeAt(current - 1), current - 1, true},
+		{"next step", " " + codeAt(current+1) + " ", current + 1, true},
+		{"too old", codeAt(current - 2), 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := matchTOTPStep(secret, tt.code, now)
+			if ok != tt.ok || step != tt.step {
+				t.Fatalf("got step %d, %v; want %d, %v", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+
+	if _, ok := matchTOTPStep("", codeAt(current), now); ok {
+		t.Fatal("code accepted without a secret")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/hex"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// reading — показник у форматі версії 2, який надсилають смарт-окуляри.
+type reading struct {
+	SchemaVersion  int       `json:"schema_version"`
+	BootID         string    `json:"boot_id"`
+	Sequence       int64     `json:"sequence"`
+	Timestamp      time.Time `json:"timestamp"`
+	PostureAngle   float64   `json:"posture_angle"`
+	AmbientLux     float64   `json:"ambient_lux"`
+	ScreenDistance float64   `json:"screen_distance_cm"`
+	BlinkRate      float64   `json:"blink_rate"`
+	BatteryLevel   float64   `json:"battery_level"`
+}
+
+// device імітує одні смарт-окуляри: чергування правильної та неправильної постави,
+// роботи з екраном і відпочинку, денне й вечірнє освітлення, розряд батареї та зникнення зв'язку.
+type device struct {
+	serialNumber string
+	apiKey       string
+	rng          *rand.Rand
+
+	bootID       string
+	sequence     int64
+	badPosture   bool
+	postureUntil time.Time
+	nearWork     bool
+	nearUntil    time.Time
+	dimLight     bool
+	lightUntil   time.Time
+	battery      float64
+	offlineUntil time.Time
+	lastCheck    time.Time
+
+	pending []reading // Показники, ще не прийняті сервером
+}
+
+// newDevice створює пристрій із власним генератором випадкових чисел.
+// Як і прошивка після запуску, кожен пристрій нумерує показники з 1 під новим boot_id,
+// тому сервер не сплутає їх із показниками попередніх запусків.
+func newDevice(serialNumber, apiKey string, seed int64) *device {
+	rng := rand.New(rand.NewSource(seed))
+	return &device{
+		serialNumber: serialNumber,
+		apiKey:       apiKey,
+		rng:          rng,
+		bootID:       newBootID(),
+		battery:      40 + rng.Float64()*60,
+	}
+}
+
+// newBootID створює випадковий boot_id. Він не залежить від -seed, щоб повторний запуск
+// з тим самим seed не видавав свої показники за вже надіслані.
+func newBootID() string {
+	buf := make([]byte, 8)
+	if _, err := crand.Read(buf); err != nil {
+		log.Fatal("Failed to generate boot id: ", err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// worn перевіряє, чи носять окуляри в момент t: якщо nightOff, вночі (з 0:00 до 6:00) показників немає.
+func worn(t time.Time, nightOff bool) bool {
+	return !nightOff || t.Hour() >= 6
+}
+
+// next створює показник для моменту t.
+func (d *device) next(t time.Time) reading {
+	// Постава: спокійні періоди в середньому 10 хв, сутулість — 4 хв
+	if !t.Before(d.postureUntil) {
+		d.badPosture = !d.badPosture
+		mean := 10 * time.Minute
+		if d.badPosture {
+			mean = 4 * time.Minute
+		}
+		d.postureUntil = t.Add(d.exponential(mean))
+	}
+	angle := d.normal(15, 6)
+	if d.badPosture {
+		angle = d.normal(55, 10)
+	}
+
+	// Робота з екраном: у середньому 25 хв роботи й 5 хв відпочинку
+	if !t.Before(d.nearUntil) {
+		d.nearWork = !d.nearWork
+		mean := 5 * time.Minute
+		if d.nearWork {
+			mean = 25 * time.Minute
+		}
+		d.nearUntil = t.Add(d.exponential(mean))
+	}
+	distance := d.normal(250, 60)
+	if d.nearWork {
+		distance = d.normal(45, 6)
+	}
+
+	// Освітлення залежить від часу доби; іноді користувач працює в напівтемряві
+	if !t.Before(d.lightUntil) {
+		d.dimLight = d.rng.Float64() < 0.2
+		d.lightUntil = t.Add(d.exponential(20 * time.Minute))
+	}
+	lux := d.ambientLux(t)
+	if d.dimLight {
+		lux *= 0.15
+	}
+
+	blinkRate := d.normal(17, 3)
+	if d.nearWork {
+		blinkRate = d.normal(9, 2) // Під час роботи з екраном кліпають рідше
+	}
+
+	d.battery -= 0.002 + d.rng.Float64()*0.002
+	if d.battery < 5 {
+		d.battery = 100 // Окуляри поставили на зарядку
+	}
+
+	d.sequence++
+	return reading{
+		SchemaVersion:  2,
+		BootID:         d.bootID,
+		Sequence:       d.sequence,
+		Timestamp:      t.UTC(),
+		PostureAngle:   nonZero(clamp(angle, -90, 90)),
+		AmbientLux:     nonZero(clamp(lux, 1, 100000)),
+		ScreenDistance: clamp(distance, 15, 1000),
+		BlinkRate:      clamp(blinkRate, 0, 120),
+		BatteryLevel:   math.Round(d.battery*10) / 10,
+	}
+}
+
+// ambientLux — типова освітленість для часу доби: денне світло, вечірня лампа, нічник.
+func (d *device) ambientLux(t time.Time) float64 {
+	hour := float64(t.Hour()) + float64(t.Minute())/60
+	switch {
+	case hour >= 8 && hour < 18:
+		// Денне світло найяскравіше опівдні
+		return d.normal(350+400*math.Sin((hour-8)/10*math.Pi), 60)
+	case hour >= 18 && hour < 23:
+		return d.normal(250, 70)
+	case hour >= 6 && hour < 8:
+		return d.normal(150, 40)
+	}
+	return d.normal(30, 10)
+}
+
+// offline перевіряє, чи пристрій зараз без зв'язку. Зв'язок зникає в середньому
+// з імовірністю probability на хвилину і відновлюється через 1..maxOffline.
+func (d *device) offline(now time.Time, probability float64, maxOffline time.Duration) bool {
+	if now.Before(d.offlineUntil) {
+		return true
+	}
+	previous := d.lastCheck
+	d.lastCheck = now
+	elapsed := now.Sub(previous)
+	if previous.IsZero() || probability <= 0 || maxOffline <= 0 || elapsed <= 0 || elapsed > time.Hour {
+		return false
+	}
+	if d.rng.Float64() < probability*elapsed.Minutes() {
+		duration := time.Minute + time.Duration(d.rng.Int63n(int64(maxOffline)))
+		d.offlineUntil = now.Add(duration)
+		return true
+	}
+	return false
+}
+
+// exponential повертає випадкову тривалість з експоненційним розподілом і середнім mean.
+func (d *device) exponential(mean time.Duration) time.Duration {
+	return time.Duration(d.rng.ExpFloat64() * float64(mean))
+}
+
+// normal повертає випадкове значення з нормальним розподілом.
+func (d *device) normal(mean, stddev float64) float64 {
+	return mean + d.rng.NormFloat64()*stddev
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+// nonZero замінює нуль найменшим значенням: сервер вважає нульові показники некоректними.
+func nonZero(value float64) float64 {
+	value = math.Round(value*10) / 10
+	if value == 0 {
+		return 0.1
+	}
+	return value
+}
@@ -0,0 +1,262 @@
+// Команда glasses-sim імітує N смарт-окулярів і надсилає їхні показники на сервер
+// через HTTP (POST /smart-glasses/batch) та/або MQTT, звітуючи про затримки та помилки.
+//
+// Пристрої мають бути зареєстровані й прив'язані до пацієнтів заздалегідь. Їхні ключі
+// передаються файлом -keys, по одному пристрою на рядок: "<серійний номер> <API-ключ>".
+//
+// Приклад:
+//
+//	go run ./cmd/glasses-sim -keys devices.txt -devices 50 -rate 1 -batch 10 -transport both \
+//		-mqtt-broker tcp://localhost:1883 -backfill 24h -duration 10m
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// options — параметри запуску симулятора.
+type options struct {
+	url            string
+	keysFile       string
+	devices        int
+	transport      string
+	rate           float64
+	batch          int
+	maxBatch       int
+	bufferSize     int
+	duration       time.Duration
+	backfill       time.Duration
+	backfillStep   time.Duration
+	offlineProb    float64
+	maxOffline     time.Duration
+	nightOff       bool
+	timeout        time.Duration
+	mqttBroker     string
+	mqttPrefix     string
+	mqttUser       string
+	mqttPass       string
+	seed           int64
+	reportInterval time.Duration
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.url, "url", "http://localhost:3000", "base URL of the API server")
+	flag.StringVar(&opts.keysFile, "keys", "", "file with one \"<serial number> <api key>\" pair per line")
+	flag.IntVar(&opts.devices, "devices", 0, "number of devices to simulate (0 = all devices from -keys)")
+	flag.StringVar(&opts.transport, "transport", "http", "ingestion path: http, mqtt or both (devices are split between them)")
+	flag.Float64Var(&opts.rate, "rate", 1, "readings per second per device")
+	flag.IntVar(&opts.batch, "batch", 10, "readings collected before a device sends them")
+	flag.IntVar(&opts.maxBatch, "max-batch", 1000, "maximum readings in one request (server limit)")
+	flag.IntVar(&opts.bufferSize, "buffer", 50000, "maximum unsent readings kept by a device; the oldest are dropped")
+	flag.DurationVar(&opts.duration, "duration", 0, "how long to run (0 = until interrupted)")
+	flag.DurationVar(&opts.backfill, "backfill", 0, "history to generate and upload before live readings, e.g. 24h")
+	flag.DurationVar(&opts.backfillStep, "backfill-step", 5*time.Second, "interval between backfilled readings")
+	flag.Float64Var(&opts.offlineProb, "offline-prob", 0.01, "probability per minute that a device loses connection")
+	flag.DurationVar(&opts.maxOffline, "max-offline", 10*time.Minute, "maximum duration of a connection loss")
+	flag.BoolVar(&opts.nightOff, "night-off", true, "do not produce readings between 00:00 and 06:00")
+	flag.DurationVar(&opts.timeout, "timeout", 10*time.Second, "request and ack timeout")
+	flag.StringVar(&opts.mqttBroker, "mqtt-broker", "", "MQTT broker URL, e.g. tcp://localhost:1883")
+	flag.StringVar(&opts.mqttPrefix, "mqtt-prefix", "orthovision/devices", "MQTT topic prefix")
+	flag.StringVar(&opts.mqttUser, "mqtt-user", "", "MQTT username")
+	flag.StringVar(&opts.mqttPass, "mqtt-pass", "", "MQTT password")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random seed")
+	flag.DurationVar(&opts.reportInterval, "report-interval", 10*time.Second, "how often to print statistics")
+	flag.Parse()
+
+	if err := validate(opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	keys, err := loadKeys(opts.keysFile)
+	if err != nil {
+		log.Fatal("Error loading device keys: ", err)
+	}
+	if opts.devices > 0 && opts.devices < len(keys) {
+		keys = keys[:opts.devices]
+	}
+
+	var senders []sender
+	if opts.transport == "http" || opts.transport == "both" {
+		senders = append(senders, newHTTPSender(opts.url, opts.timeout))
+	}
+	if opts.transport == "mqtt" || opts.transport == "both" {
+		mqttSender, err := newMQTTSender(opts.mqttBroker, opts.mqttUser, opts.mqttPass, opts.mqttPrefix, opts.timeout)
+		if err != nil {
+			log.Fatal("Error connecting to MQTT broker: ", err)
+		}
+		defer mqttSender.close()
+		senders = append(senders, mqttSender)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if opts.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	log.Printf("Simulating %d devices via %s at %.2f readings/s each", len(keys), opts.transport, opts.rate)
+	started := time.Now()
+	st := newStats()
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		d := newDevice(key[0], key[1], opts.seed+int64(i))
+		s := senders[i%len(senders)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx, d, s, st, opts)
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(opts.reportInterval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case now := <-ticker.C:
+				fmt.Println(st.flush(now.Sub(last)))
+				last = now
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	fmt.Printf("Summary after %s:\n%s\n", time.Since(started).Round(time.Second), st.summary(time.Since(started)))
+}
+
+// validate перевіряє параметри запуску.
+func validate(opts options) error {
+	switch {
+	case opts.keysFile == "":
+		return errors.New("-keys is required")
+	case opts.transport != "http" && opts.transport != "mqtt" && opts.transport != "both":
+		return errors.New("-transport must be http, mqtt or both")
+	case opts.transport != "http" && opts.mqttBroker == "":
+		return errors.New("-mqtt-broker is required for MQTT transport")
+	case opts.rate <= 0:
+		return errors.New("-rate must be positive")
+	case readingInterval(opts.rate) <= 0:
+		return errors.New("-rate is too high")
+	case opts.batch < 1 || opts.maxBatch < 1:
+		return errors.New("-batch and -max-batch must be positive")
+	case opts.backfill > 0 && opts.backfillStep <= 0:
+		return errors.New("-backfill-step must be positive")
+	case opts.reportInterval <= 0:
+		return errors.New("-report-interval must be positive")
+	}
+	return nil
+}
+
+// loadKeys читає пари "<серійний номер> <API-ключ>". Порожні рядки й рядки, що починаються з '#', пропускаються.
+func loadKeys(path string) ([][2]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys [][2]string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<serial number> <api key>\"", line)
+		}
+		keys = append(keys, [2]string{fields[0], fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no devices in keys file")
+	}
+	return keys, nil
+}
+
+// readingInterval повертає проміжок між показниками пристрою для частоти rate показників за секунду.
+// Для надто великої частоти проміжок округлюється до нуля.
+func readingInterval(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// run імітує один пристрій: спочатку вивантажує історію за -backfill, потім створює показники
+// з частотою -rate. Поки зв'язку немає, показники накопичуються і надсилаються після його відновлення.
+// Пакети, які не вдалося надіслати, повторюються — сервер відкидає повтори за номером послідовності.
+// Після завершення роботи накопичені показники надсилаються ще раз; ті, що так і не вдалося
+// надіслати, а також витіснені з переповненого буфера, враховуються в статистиці.
+func run(ctx context.Context, d *device, s sender, st *stats, opts options) {
+	if opts.backfill > 0 {
+		now := time.Now()
+		for t := now.Add(-opts.backfill); t.Before(now); t = t.Add(opts.backfillStep) {
+			if worn(t, opts.nightOff) {
+				d.pending = append(d.pending, d.next(t))
+			}
+		}
+		d.lastCheck = now
+	}
+
+	ticker := time.NewTicker(readingInterval(opts.rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Контекст уже завершено, тому для останньої спроби потрібен новий
+			flush(context.Background(), d, s, st, opts.maxBatch)
+			st.recordUnsent(s.name(), len(d.pending))
+			return
+		case now := <-ticker.C:
+			if worn(now, opts.nightOff) {
+				d.pending = append(d.pending, d.next(now))
+			}
+			if overflow := len(d.pending) - opts.bufferSize; opts.bufferSize > 0 && overflow > 0 {
+				d.pending = d.pending[overflow:]
+				st.recordDropped(s.name(), overflow)
+			}
+			if len(d.pending) < opts.batch || d.offline(now, opts.offlineProb, opts.maxOffline) {
+				continue
+			}
+			flush(ctx, d, s, st, opts.maxBatch)
+		}
+	}
+}
+
+// flush надсилає накопичені показники пакетами не більше maxBatch. Після першої помилки
+// надсилання припиняється, а решта показників лишається до наступної спроби.
+func flush(ctx context.Context, d *device, s sender, st *stats, maxBatch int) {
+	for len(d.pending) > 0 && ctx.Err() == nil {
+		size := min(len(d.pending), maxBatch)
+		batch := d.pending[:size]
+
+		started := time.Now()
+		res, err := s.send(d, batch)
+		st.record(s.name(), size, time.Since(started), res, err)
+		if err != nil {
+			log.Printf("%s %s: %v", s.name(), d.serialNumber, err)
+			return
+		}
+		d.pending = d.pending[size:]
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// result — відповідь сервера на один надісланий пакет.
+type result struct {
+	accepted   int
+	duplicates int
+	rejected   int
+}
+
+// counters — лічильники одного способу надсилання (HTTP або MQTT).
+type counters struct {
+	requests   int
+	readings   int
+	accepted   int
+	duplicates int
+	rejected   int
+	errors     int
+	dropped    int // Витіснені з переповненого буфера пристрою
+	unsent     int // Лишилися ненадісланими після завершення роботи
+	latencies  []time.Duration
+}
+
+// stats збирає лічильники й затримки за поточний інтервал звіту та за весь запуск.
+type stats struct {
+	mu       sync.Mutex
+	interval map[string]*counters
+	total    map[string]*counters
+}
+
+func newStats() *stats {
+	return &stats{
+		interval: make(map[string]*counters),
+		total:    make(map[string]*counters),
+	}
+}
+
+// record записує результат надсилання пакета з readings показників.
+func (s *stats) record(transport string, readings int, latency time.Duration, res result, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, set := range []map[string]*counters{s.interval, s.total} {
+		c := counter(set, transport)
+		c.requests++
+		c.readings += readings
+		if err != nil {
+			c.errors++
+			continue
+		}
+		c.accepted += res.accepted
+		c.duplicates += res.duplicates
+		c.rejected += res.rejected
+		c.latencies = append(c.latencies, latency)
+	}
+}
+
+// recordDropped записує показники, витіснені з переповненого буфера пристрою.
+func (s *stats) recordDropped(transport string, readings int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, set := range []map[string]*counters{s.interval, s.total} {
+		counter(set, transport).dropped += readings
+	}
+}
+
+// recordUnsent записує показники, що лишилися ненадісланими після завершення роботи пристрою.
+func (s *stats) recordUnsent(transport string, readings int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, set := range []map[string]*counters{s.interval, s.total} {
+		counter(set, transport).unsent += readings
+	}
+}
+
+// counter повертає лічильники способу надсилання transport, створюючи їх за потреби.
+func counter(set map[string]*counters, transport string) *counters {
+	c := set[transport]
+	if c == nil {
+		c = &counters{}
+		set[transport] = c
+	}
+	return c
+}
+
+// flush повертає рядок звіту за інтервал і починає новий інтервал.
+func (s *stats) flush(elapsed time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line := format(s.interval, elapsed)
+	s.interval = make(map[string]*counters)
+	return line
+}
+
+// summary повертає підсумок за весь запуск.
+func (s *stats) summary(elapsed time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return format(s.total, elapsed)
+}
+
+// format подає лічильники у вигляді одного рядка на кожен спосіб надсилання.
+func format(set map[string]*counters, elapsed time.Duration) string {
+	if len(set) == 0 {
+		return "no requests"
+	}
+	transports := make([]string, 0, len(set))
+	for transport := range set {
+		transports = append(transports, transport)
+	}
+	sort.Strings(transports)
+
+	lines := make([]string, 0, len(transports))
+	for _, transport := range transports {
+		c := set[transport]
+		rate := 0.0
+		if elapsed > 0 {
+			rate = float64(c.readings) / elapsed.Seconds()
+		}
+		lines = append(lines, fmt.Sprintf(
+			"%-4s req=%d readings=%d (%.1f/s) accepted=%d duplicates=%d rejected=%d errors=%d dropped=%d unsent=%d latency p50=%s p95=%s p99=%s max=%s",
+			transport, c.requests, c.readings, rate, c.accepted, c.duplicates, c.rejected, c.errors, c.dropped, c.unsent,
+			percentile(c.latencies, 0.50), percentile(c.latencies, 0.95), percentile(c.latencies, 0.99), percentile(c.latencies, 1),
+		))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// percentile повертає перцентиль p (0..1) затримок, округлений до мілісекунди.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(p*float64(len(sorted))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index].Round(time.Millisecond)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// sender надсилає пакет показників від імені пристрою і повертає відповідь сервера.
+type sender interface {
+	name() string
+	send(d *device, batch []reading) (result, error)
+}
+
+// httpSender надсилає показники через POST /smart-glasses/batch.
+type httpSender struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSender(baseURL string, timeout time.Duration) *httpSender {
+	return &httpSender{
+		url:    strings.TrimSuffix(baseURL, "/") + "/smart-glasses/batch",
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *httpSender) name() string { return "http" }
+
+func (s *httpSender) send(d *device, batch []reading) (result, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return result{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Device-Key", d.apiKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return result{}, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result{}, err
+	}
+
+	var response struct {
+		Accepted   int    `json:"accepted"`
+		Duplicates int    `json:"duplicates"`
+		Rejected   int    `json:"rejected"`
+		Error      string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return result{}, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result{}, fmt.Errorf("HTTP %d: %s", resp.StatusCode, response.Error)
+	}
+	return result{
+		accepted:   response.Accepted,
+		duplicates: response.Duplicates,
+		rejected:   response.Rejected,
+	}, nil
+}
+
+// mqttSender публікує показники в топік <prefix>/<серійний номер>/telemetry і чекає
+// на відповідь сервера в <prefix>/<серійний номер>/ack. Усі пристрої використовують одне
+// з'єднання, тому обліковий запис симулятора на брокері має право публікувати в топіки всіх пристроїв.
+type mqttSender struct {
+	client  mqtt.Client
+	prefix  string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	acks map[string]chan []byte
+}
+
+func newMQTTSender(broker, username, password, prefix string, timeout time.Duration) (*mqttSender, error) {
+	s := &mqttSender{
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		timeout: timeout,
+		acks:    make(map[string]chan []byte),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("glasses-sim-%d", time.Now().UnixNano())).
+		SetUsername(username).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetOrderMatters(false)
+	// Підписуємося під час кожного з'єднання, щоб підписка відновлювалася після перепідключення
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		token := client.Subscribe(s.prefix+"/+/ack", 0, s.handleAck)
+		if token.WaitTimeout(timeout) && token.Error() != nil {
+			fmt.Println("MQTT subscribe error:", token.Error())
+		}
+	})
+
+	s.client = mqtt.NewClient(opts)
+	token := s.client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return nil, errors.New("MQTT connection timeout")
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *mqttSender) name() string { return "mqtt" }
+
+// close від'єднується від брокера.
+func (s *mqttSender) close() {
+	s.client.Disconnect(250)
+}
+
+// ackChannel повертає канал відповідей для пристрою, створюючи його за потреби.
+func (s *mqttSender) ackChannel(serialNumber string) chan []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.acks[serialNumber]
+	if !ok {
+		ch = make(chan []byte, 1)
+		s.acks[serialNumber] = ch
+	}
+	return ch
+}
+
+// handleAck передає відповідь сервера пристрою, якому вона адресована.
+func (s *mqttSender) handleAck(_ mqtt.Client, msg mqtt.Message) {
+	rest := strings.TrimPrefix(msg.Topic(), s.prefix+"/")
+	serialNumber := strings.TrimSuffix(rest, "/ack")
+	select {
+	case s.ackChannel(serialNumber) <- msg.Payload():
+	default:
+		// Відповідь, на яку вже ніхто не чекає (після тайм-ауту), відкидається
+	}
+}
+
+func (s *mqttSender) send(d *device, batch []reading) (result, error) {
+	// Сервер приймає повідомлення лише з API-ключем пристрою
+	body, err := json.Marshal(map[string]interface{}{
+		"device_key": d.apiKey,
+		"readings":   batch,
+	})
+	if err != nil {
+		return result{}, err
+	}
+
+	acks := s.ackChannel(d.serialNumber)
+	// Відкидаємо запізнілу відповідь на попередній пакет
+	select {
+	case <-acks:
+	default:
+	}
+
+	token := s.client.Publish(s.prefix+"/"+d.serialNumber+"/telemetry", 1, false, body)
+	if !token.WaitTimeout(s.timeout) {
+		return result{}, errors.New("MQTT publish timeout")
+	}
+	if err := token.Error(); err != nil {
+		return result{}, err
+	}
+
+	select {
+	case payload := <-acks:
+		return parseAck(payload)
+	case <-time.After(s.timeout):
+		return result{}, errors.New("MQTT ack timeout")
+	}
+}
+
+// parseAck розбирає відповідь сервера {"results":[...]} або {"error":"..."}.
+func parseAck(payload []byte) (result, error) {
+	var ack struct {
+		Results []struct {
+			Status string `json:"status"`
+		} `json:"results"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return result{}, err
+	}
+	if ack.Error != "" {
+		return result{}, errors.New(ack.Error)
+	}
+
+	var res result
+	for _, item := range ack.Results {
+		switch item.Status {
+		case "accepted":
+			res.accepted++
+		case "duplicate":
+			res.duplicates++
+		default:
+			res.rejected++
+		}
+	}
+	return res, nil
+}
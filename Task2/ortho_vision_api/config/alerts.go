@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// Налаштування сповіщень про тривалі порушення постави й освітлення.
+// Сповіщення відкривається, коли порушення триває довше за вказаний час.
+var (
+	AlertPostureDuration   = 15 * time.Minute // Тривалість надмірного нахилу голови
+	AlertLowLightDuration  = 30 * time.Minute // Тривалість недостатнього освітлення
+	AlertHighLightDuration = 30 * time.Minute // Тривалість надмірного освітлення
+	AlertMaxGap            = 5 * time.Minute  // Перерва в показниках, після якої порушення вважається перерваним
+	AlertQueueSize         = 1024             // Кількість пакетів показників у черзі на перевірку; при переповненні нові пакети не перевіряються
+)
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTSecret — секретний ключ для підпису токенів доступу (змінна JWT_SECRET).
+// Значення за замовчуванням немає: без секрету сервер не запускається (див. CheckJWTSecret).
+var JWTSecret = []byte(os.Getenv("JWT_SECRET"))
+
+// MinJWTSecretLength — рекомендована довжина JWT_SECRET, байт (розмір ключа HMAC-SHA256).
+const MinJWTSecretLength = 32
+
+// CheckJWTSecret перевіряє, що JWT_SECRET задано і він не порожній. Про надто короткий
+// секрет лише попереджає, щоб не зупиняти вже розгорнуті сервери.
+func CheckJWTSecret() error {
+	if strings.TrimSpace(string(JWTSecret)) == "" {
+		return errors.New("JWT_SECRET must be set to a non-empty value")
+	}
+	if len(JWTSecret) < MinJWTSecretLength {
+		log.Printf("JWT_SECRET is shorter than %d bytes, use a longer random secret", MinJWTSecretLength)
+	}
+	return nil
+}
+
+// AccessTokenTTL — час життя токена доступу.
+var AccessTokenTTL = 15 * time.Minute
+
+// RefreshTokenTTL — час життя токена оновлення (сесії пристрою).
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// MFATokenTTL — час, за який потрібно ввести код TOTP після перевірки пароля.
+var MFATokenTTL = 5 * time.Minute
+
+// TOTPIssuer — назва сервісу, яку показує застосунок-автентифікатор.
+var TOTPIssuer = getEnv("TOTP_ISSUER", "Ortho Vision")
+
+// TOTPRecoveryCodeCount — кількість кодів відновлення, що видаються користувачу.
+var TOTPRecoveryCodeCount = 10
+
+// getEnv повертає значення змінної оточення або значення за замовчуванням.
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Налаштування захисту від підбору пароля.
+// LOGIN_GUARD_STORE: "memory" — для одного екземпляра, "postgres" — спільне сховище для кількох реплік.
+var (
+	LoginGuardStore = getEnv("LOGIN_GUARD_STORE", "memory")
+
+	// Обмеження для облікового запису
+	LoginAccountMaxFailures = 5
+	LoginAccountLockout     = 15 * time.Minute
+
+	// Обмеження для IP-адреси (вище, бо за одним IP можуть бути різні користувачі)
+	LoginIPMaxFailures = 20
+	LoginIPLockout     = 15 * time.Minute
+	LoginIPBaseDelay   = 250 * time.Millisecond // Затримка для IP-адреси зростає повільніше, ніж для облікового запису
+	LoginIPMaxDelay    = 10 * time.Second
+
+	// Прогресивна затримка між невдалими спробами: 1с, 2с, 4с ... до LoginMaxDelay
+	LoginBaseDelay = time.Second
+	LoginMaxDelay  = 30 * time.Second
+
+	// Після цього часу без невдалих спроб лічильник обнуляється
+	LoginFailureWindow = 15 * time.Minute
+)
+
+// Налаштування входу через OpenID Connect (Google або інший провайдер).
+// Вхід через OIDC вмикається, якщо задано OIDC_CLIENT_ID.
+// OIDC_ISSUER можна змінити, наприклад, на локальний тестовий провайдер.
+var (
+	OIDCIssuer       = getEnv("OIDC_ISSUER", "https://accounts.google.com")
+	OIDCClientID     = getEnv("OIDC_CLIENT_ID", "")
+	OIDCClientSecret = getEnv("OIDC_CLIENT_SECRET", "")
+	OIDCRedirectURL  = getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/auth/oidc/callback")
+
+	// OIDCLoginTTL — скільки часу користувач має на вхід у провайдера
+	OIDCLoginTTL = 10 * time.Minute
+)
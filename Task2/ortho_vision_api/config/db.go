@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"ortho_vision_api/models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -43,10 +44,109 @@ func InitDB() *gorm.DB {
 		log.Println("Successfully connected to the database.")
 	}
 
+	// Освітленість спершу зберігалася в колонці eye_strain; перейменовуємо до AutoMigrate,
+	// інакше AutoMigrate створить нову порожню колонку ambient_lux
+	renameColumn(&models.SmartGlassesData{}, "eye_strain", "ambient_lux")
+	for _, aggregate := range []string{"sum", "min", "max"} {
+		renameColumn(&models.SmartGlassesMinuteRollup{}, "eye_strain_"+aggregate, "ambient_lux_"+aggregate)
+		renameColumn(&models.SmartGlassesHourRollup{}, "eye_strain_"+aggregate, "ambient_lux_"+aggregate)
+	}
+
+	// Облікові записи, створені до появи підтвердження email, вважаються підтвердженими,
+	// інакше вони втратили б доступ до запису на прийом
+	backfillEmailVerified()
+
+	// Таблиця клінік не входить до AutoMigrate, тому нову колонку додаємо окремо
+	addColumn(&models.Clinic{}, "Timezone")
+
+	// Номери послідовності тепер унікальні в межах boot_id; старий індекс без boot_id
+	// не дав би зберегти показники після перепрошивки пристрою
+	dropIndex(&models.SmartGlassesData{}, "idx_smartglassesdata_device_seq")
+
 	// Автоматичне створення таблиць при запуску програми (якщо їх немає).
 	// Якщо потрібно зробити тільки міграцію, можна замінити db.AutoMigrate() на інші міграційні інструменти.
-	DB.AutoMigrate()
+	if err := DB.AutoMigrate(
+		&models.User{},
+		&models.RefreshToken{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.LoginAttempt{},
+		&models.ExternalIdentity{},
+		&models.OIDCLoginRequest{},
+		&models.TOTPRecoveryCode{},
+		&models.TwoFactorPolicy{},
+		&models.UserAuditLog{},
+		&models.SmartGlassesDevice{},
+		&models.SmartGlassesData{},
+		&models.ThresholdProfile{},
+		&models.Alert{},
+		&models.SmartGlassesMinuteRollup{},
+		&models.SmartGlassesHourRollup{},
+		&models.SmartGlassesRollupPending{},
+		&models.SmartGlassesRollupState{},
+		&models.SmartGlassesRollupWatermark{},
+		&models.ExerciseProgram{},
+		&models.ExerciseStep{},
+		&models.ExerciseAssignment{},
+		&models.ExerciseCompletion{},
+		&models.WeeklyReport{},
+		&models.TelemetryExport{},
+	); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 
 	// Повертаємо підключення до БД для використання в інших частинах програми.
 	return DB
 }
+
+// renameColumn перейменовує колонку, якщо таблиця ще має стару назву колонки.
+func renameColumn(model interface{}, oldName, newName string) {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(model) || !migrator.HasColumn(model, oldName) || migrator.HasColumn(model, newName) {
+		return
+	}
+	if err := migrator.RenameColumn(model, oldName, newName); err != nil {
+		log.Fatal("Failed to rename column ", oldName, ": ", err)
+	}
+}
+
+// addColumn додає до наявної таблиці колонку для поля моделі, якщо її ще немає.
+func addColumn(model interface{}, field string) {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(model) || migrator.HasColumn(model, field) {
+		return
+	}
+	if err := migrator.AddColumn(model, field); err != nil {
+		log.Fatal("Failed to add column ", field, ": ", err)
+	}
+}
+
+// dropIndex видаляє індекс, якщо він є.
+func dropIndex(model interface{}, name string) {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(model) || !migrator.HasIndex(model, name) {
+		return
+	}
+	if err := migrator.DropIndex(model, name); err != nil {
+		log.Fatal("Failed to drop index ", name, ": ", err)
+	}
+}
+
+// backfillEmailVerified одноразово додає колонку email_verified до наявної таблиці користувачів
+// і позначає всіх уже зареєстрованих користувачів як таких, що підтвердили email.
+// Колонка й позначка додаються в одній транзакції, тому перерваний запуск просто повториться.
+func backfillEmailVerified() {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.User{}) || migrator.HasColumn(&models.User{}, "EmailVerified") {
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&models.User{}, "EmailVerified"); err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("1 = 1").Update("email_verified", true).Error
+	})
+	if err != nil {
+		log.Fatal("Failed to backfill email verification: ", err)
+	}
+}
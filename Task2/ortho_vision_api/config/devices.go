@@ -0,0 +1,6 @@
+package config
+
+import "time"
+
+// DevicePairingCodeTTL — час дії коду для прив'язки смарт-окулярів до пацієнта.
+var DevicePairingCodeTTL = 72 * time.Hour
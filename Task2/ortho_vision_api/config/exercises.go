@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// Обмеження програм світлодіодних вправ для очей.
+var (
+	ExerciseMaxSteps          = 50                  // Найбільша кількість кроків у програмі
+	ExerciseMaxStepDuration   = 10 * time.Minute    // Найбільша тривалість одного кроку
+	ExerciseMaxSessionsPerDay = 24                  // Найбільша кількість сеансів на добу
+	ExerciseAdherenceMaxRange = 92 * 24 * time.Hour // Найбільший період звіту про виконання вправ
+)
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// Налаштування експорту показників для досліджень.
+// Потоковий експорт віддає файл одразу й обмежений коротким періодом; довші періоди
+// експортуються фоновими завданнями у файли в EXPORT_DIR. Якщо запущено кілька екземплярів
+// сервера, EXPORT_DIR має бути спільним для них, інакше файл можна завантажити лише з того
+// екземпляра, що його створив.
+var (
+	ExportDir             = getEnv("EXPORT_DIR", "exports")
+	ExportInterval        = 10 * time.Second     // Як часто перевіряються нові завдання й застарілі файли
+	ExportStreamMaxRange  = 7 * 24 * time.Hour   // Найдовший період потокового експорту
+	ExportMaxRange        = 400 * 24 * time.Hour // Найдовший період фонового експорту
+	ExportFileTTL         = 7 * 24 * time.Hour   // Скільки зберігається готовий файл
+	ExportHeartbeat       = 30 * time.Second     // Як часто завдання, що виконується, позначає, що воно ще працює
+	ExportStaleAfter      = 5 * time.Minute      // Завдання без позначки довше за цей час вважається перерваним і запускається знову
+	ExportParquetRowGroup = int64(16 << 20)      // Розмір групи рядків Parquet, байт (стільки даних тримається в пам'яті)
+)
@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// Налаштування відправлення листів.
+// MAILER: "smtp" — реальна відправка, "log" — запис листів у журнал або файл (для розробки й тестів).
+var (
+	MailerDriver = getEnv("MAILER", "log")
+	MailFrom     = getEnv("MAIL_FROM", "no-reply@orthovision.local")
+	MailLogFile  = getEnv("MAIL_LOG_FILE", "")
+
+	SMTPHost     = getEnv("SMTP_HOST", "localhost")
+	SMTPPort     = getEnv("SMTP_PORT", "587")
+	SMTPUser     = getEnv("SMTP_USER", "")
+	SMTPPassword = getEnv("SMTP_PASSWORD", "")
+)
+
+// AppBaseURL — адреса клієнтського застосунку, на яку ведуть посилання в листах.
+var AppBaseURL = getEnv("APP_BASE_URL", "http://localhost:3000")
+
+// PasswordResetTTL — час дії посилання для скидання пароля.
+var PasswordResetTTL = time.Hour
+
+// EmailVerificationTTL — час дії посилання для підтвердження електронної адреси.
+var EmailVerificationTTL = 24 * time.Hour
+
+// Обмеження на повторне надсилання листа з підтвердженням.
+var (
+	VerificationResendInterval = time.Minute // Мінімальний інтервал між листами
+	VerificationResendPerHour  = 5           // Максимальна кількість листів за годину
+)
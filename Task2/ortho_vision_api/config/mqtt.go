@@ -0,0 +1,33 @@
+package config
+
+import "os"
+
+// Налаштування отримання показників смарт-окулярів через MQTT.
+// Якщо MQTT_BROKER_URL не задано, підписка на брокер не запускається.
+// Пристрої автентифікуються на брокері, а його ACL дозволяє кожному пристрою
+// публікувати лише у власний топік <MQTT_TOPIC_PREFIX>/<серійний номер>/telemetry.
+// Незалежно від ACL сервер приймає повідомлення, лише якщо в ньому є API-ключ цього пристрою.
+//
+// Кілька екземплярів сервера підписуються спільною підпискою $share/<MQTT_SHARED_GROUP>/...,
+// тож кожне повідомлення обробляє лише один із них; брокер має підтримувати спільні підписки
+// (MQTT 5 або відповідне розширення MQTT 3.1.1, як у Mosquitto, EMQX, HiveMQ).
+// ID клієнта кожного екземпляра — <MQTT_CLIENT_ID>-<MQTT_INSTANCE_ID>; за замовчуванням
+// MQTT_INSTANCE_ID — ім'я хоста, тому після перезапуску екземпляр відновлює свою сесію на брокері.
+var (
+	MQTTBrokerURL   = getEnv("MQTT_BROKER_URL", "")
+	MQTTClientID    = getEnv("MQTT_CLIENT_ID", "ortho_vision_api")
+	MQTTInstanceID  = getEnv("MQTT_INSTANCE_ID", hostname())
+	MQTTSharedGroup = getEnv("MQTT_SHARED_GROUP", "ortho_vision_api")
+	MQTTUsername    = getEnv("MQTT_USERNAME", "")
+	MQTTPassword    = getEnv("MQTT_PASSWORD", "")
+	MQTTTopicPrefix = getEnv("MQTT_TOPIC_PREFIX", "orthovision/devices")
+)
+
+// hostname повертає ім'я хоста або "local", якщо його не вдалося визначити.
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "local"
+	}
+	return name
+}
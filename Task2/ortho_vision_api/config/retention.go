@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// Налаштування агрегації показників смарт-окулярів.
+var (
+	RollupInterval = time.Minute // Як часто запускається агрегація і очищення
+	RollupLag      = time.Minute // Скільки чекати на показники поточної хвилини перед агрегацією
+)
+
+// Терміни зберігання показників; 0 — зберігати без обмежень.
+// Сирі показники видаляються лише після того, як їх враховано в агрегатах.
+var (
+	RetentionRaw    = 90 * 24 * time.Hour  // Сирі показники (smartglassesdata)
+	RetentionMinute = 365 * 24 * time.Hour // Похвилинні агрегати
+	RetentionHour   = time.Duration(0)     // Погодинні агрегати
+)
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// Налаштування обліку роботи на близькій відстані та перерв за правилом 20-20-20:
+// кожні 20 хвилин роботи з екраном слід 20 секунд дивитися вдалину.
+var (
+	NearWorkMaxDistance = 60.0             // Найбільша відстань до екрана, що вважається роботою на близькій відстані, см
+	BreakInterval       = 20 * time.Minute // Робота без перерви, після якої перерва вважається належною
+	BreakMinDuration    = 20 * time.Second // Найкоротший погляд удалину (показники дальньої відстані), що зараховується як перерва
+	BreakGrace          = time.Minute      // Допустиме запізнення перерви, за якого відрізок роботи ще вважається дотриманим
+	SessionMaxPause     = 5 * time.Minute  // Пауза, після якої починається новий сеанс роботи
+	BreakStatusWindow   = 2 * time.Hour    // Період показників, за яким пристрою визначається поточний стан роботи
+)
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// Обмеження для показників, що надсилають смарт-окуляри.
+var (
+	TelemetryMaxBatchSize = 1000                // Максимальна кількість показників в одному пакеті
+	TelemetryMaxClockSkew = 5 * time.Minute     // Допустиме відставання годинника сервера від пристрою
+	TelemetryMaxAge       = 30 * 24 * time.Hour // Найстаріший показник, який ще приймається
+)
+
+// Налаштування потоку показників у реальному часі.
+var (
+	LiveStreamBuffer    = 64               // Кількість показників у черзі одного глядача; при переповненні нові відкидаються
+	LiveStreamHeartbeat = 15 * time.Second // Інтервал службових повідомлень, за якими виявляється розрив з'єднання і перевіряється сесія глядача
+)
+
+// Порогові значення за замовчуванням, якщо ні клініка, ні лікар не задали власних.
+var (
+	DefaultMaxPostureAngle = 45.0   // Максимальний нахил голови, °
+	DefaultMinLux          = 100.0  // Мінімальна освітленість, lux
+	DefaultMaxLux          = 1000.0 // Максимальна освітленість, lux
+)
+
+// Обмеження статистики показників.
+var (
+	StatsMaxRange = 366 * 24 * time.Hour // Найбільший період, за який можна запитати статистику
+	StatsMaxGap   = 5 * time.Minute      // Найбільший проміжок між показниками, що зараховується до тривалості порушення
+)
+
+// Обмеження звіту про пропуски в номерах послідовності показників.
+var (
+	SequenceGapsDefaultLimit = 100  // Кількість пропусків у відповіді за замовчуванням
+	SequenceGapsMaxLimit     = 1000 // Найбільша кількість пропусків в одній відповіді
+)
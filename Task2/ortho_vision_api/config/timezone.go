@@ -0,0 +1,5 @@
+package config
+
+// DefaultTimezone — часовий пояс IANA, у якому рахуються доби статистики,
+// якщо ні пацієнт, ні його клініка не вказали власного.
+var DefaultTimezone = getEnv("DEFAULT_TIMEZONE", "Europe/Kyiv")
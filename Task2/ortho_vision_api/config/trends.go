@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// Налаштування аналізу тенденцій і щотижневих звітів.
+// Щоденні частки часу з порушеннями за звітний тиждень порівнюються з базовою лінією —
+// тими самими частками за попередні тижні.
+var (
+	TrendInterval         = time.Hour // Як часто перевіряється, чи є завершені тижні без звіту
+	TrendBaselineWeeks    = 4         // Кількість попередніх тижнів у базовій лінії
+	TrendMinWornMinutes   = 30        // Найменший час носіння окулярів, за якого доба враховується, хв
+	TrendMinReportDays    = 3         // Найменша кількість врахованих діб у звітному тижні
+	TrendMinBaselineDays  = 7         // Найменша кількість врахованих діб у базовій лінії
+	TrendSignificanceZ    = 1.645     // Критичне значення z (однобічний рівень значущості 5%)
+	TrendMinShareIncrease = 0.05      // Найменше зростання частки часу з порушеннями, що вважається погіршенням
+)
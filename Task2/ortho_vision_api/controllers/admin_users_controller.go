@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Обмеження пагінації для списків
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListUsers - функція для отримання списку користувачів з фільтрами і пагінацією (для адміністратора)
+func ListUsers(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	query := db.Model(&models.User{})
+
+	// Фільтр за роллю
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	// Фільтр за статусом облікового запису
+	switch c.Query("status") {
+	case "active":
+		query = query.Where("deactivated_at IS NULL")
+	case "deactivated":
+		query = query.Where("deactivated_at IS NOT NULL")
+	case "":
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Status must be 'active' or 'deactivated'",
+		})
+	}
+
+	// Пошук за ім'ям або email без урахування регістру
+	if search := strings.TrimSpace(c.Query("search")); search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+
+	page, pageSize := pagination(c)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Error counting users:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching users",
+		})
+	}
+
+	var users []models.User
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users).Error; err != nil {
+		log.Println("Error fetching users:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching users",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":   "Users retrieved successfully",
+		"data":      users,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// ChangeUserRole - функція для зміни ролі користувача із записом у журнал (для адміністратора)
+func ChangeUserRole(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Role   string `json:"role"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+	if requestData.Role != models.RolePatient && requestData.Role != models.RoleDoctor && requestData.Role != models.RoleAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Role must be one of: patient, doctor, admin",
+		})
+	}
+
+	actor := middleware.CurrentUser(c)
+	if middleware.IsSelf(c, c.Params("id")) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "You cannot change your own role",
+		})
+	}
+
+	user, err := findUserByParam(c, db)
+	if user == nil {
+		return err
+	}
+
+	if user.Role == requestData.Role {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "User already has this role",
+			"user":    user,
+		})
+	}
+
+	oldRole := user.Role
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", requestData.Role).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserAuditLog{
+			ActorID:      actor.ID,
+			TargetUserID: user.ID,
+			Action:       models.AuditActionRoleChanged,
+			OldValue:     oldRole,
+			NewValue:     requestData.Role,
+			Reason:       requestData.Reason,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error changing user role:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error changing user role",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User role changed successfully",
+		"user":    user,
+	})
+}
+
+// DeactivateUser - функція для деактивації облікового запису без видалення медичної історії (для адміністратора)
+func DeactivateUser(c *fiber.Ctx) error {
+	return setUserActive(c, false)
+}
+
+// ReactivateUser - функція для повторної активації облікового запису (для адміністратора)
+func ReactivateUser(c *fiber.Ctx) error {
+	return setUserActive(c, true)
+}
+
+// GetUserAuditLog - функція для отримання журналу змін облікового запису (для адміністратора)
+func GetUserAuditLog(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var entries []models.UserAuditLog
+	if err := db.Where("target_user_id = ?", c.Params("id")).Order("created_at DESC").Find(&entries).Error; err != nil {
+		log.Println("Error fetching audit log:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching audit log",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Audit log retrieved successfully",
+		"data":    entries,
+	})
+}
+
+// setUserActive змінює статус облікового запису та записує дію в журнал
+func setUserActive(c *fiber.Ctx, active bool) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Reason string `json:"reason"`
+	}
+	// Тіло запиту необов'язкове
+	_ = c.BodyParser(&requestData)
+
+	actor := middleware.CurrentUser(c)
+	if !active && middleware.IsSelf(c, c.Params("id")) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "You cannot deactivate your own account",
+		})
+	}
+
+	user, err := findUserByParam(c, db)
+	if user == nil {
+		return err
+	}
+
+	if user.IsActive() == active {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "User status is unchanged",
+			"user":    user,
+		})
+	}
+
+	action := models.AuditActionReactivated
+	var deactivatedAt *time.Time
+	if !active {
+		action = models.AuditActionDeactivated
+		now := time.Now()
+		deactivatedAt = &now
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("deactivated_at", deactivatedAt).Error; err != nil {
+			return err
+		}
+		// Деактивація завершує всі сесії користувача
+		if !active {
+			if err := auth.RevokeAllSessions(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&models.UserAuditLog{
+			ActorID:      actor.ID,
+			TargetUserID: user.ID,
+			Action:       action,
+			Reason:       requestData.Reason,
+		}).Error
+	})
+	if err != nil {
+		log.Println("Error changing user status:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error changing user status",
+		})
+	}
+
+	message := "User reactivated successfully"
+	if !active {
+		message = "User deactivated successfully"
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": message,
+		"user":    user,
+	})
+}
+
+// findUserByParam знаходить користувача за параметром :id.
+// Якщо користувача не знайдено, надсилає відповідь і повертає nil.
+func findUserByParam(c *fiber.Ctx, db *gorm.DB) (*models.User, error) {
+	var user models.User
+	if err := db.First(&user, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "User not found",
+			})
+		}
+		log.Println("Error finding user:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding user",
+		})
+	}
+	return &user, nil
+}
+
+// pagination читає параметри page і page_size із запиту з допустимими значеннями за замовчуванням
+func pagination(c *fiber.Ctx) (int, int) {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := c.QueryInt("page_size", defaultPageSize)
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// escapeLike екранує спеціальні символи шаблону LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
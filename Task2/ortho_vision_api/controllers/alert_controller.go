@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetAlerts - функція для отримання сповіщень про порушення постави й освітлення.
+// Пацієнт бачить лише власні сповіщення; лікар і адміністратор можуть фільтрувати за patient_id.
+// Додатково підтримуються фільтри status і type та пагінація page і page_size.
+func GetAlerts(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	query := db.Model(&models.Alert{})
+	if user.HasRole(models.RoleDoctor, models.RoleAdmin) {
+		if patientID := c.Query("patient_id"); patientID != "" {
+			query = query.Where("patient_id = ?", patientID)
+		}
+	} else {
+		query = query.Where("patient_id = ?", user.ID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if alertType := c.Query("type"); alertType != "" {
+		query = query.Where("type = ?", alertType)
+	}
+
+	page, pageSize := pagination(c)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Error counting alerts:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching alerts",
+		})
+	}
+
+	var alerts []models.Alert
+	if err := query.Order("opened_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&alerts).Error; err != nil {
+		log.Println("Error fetching alerts:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching alerts",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":   "Alerts retrieved successfully",
+		"data":      alerts,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// AcknowledgeAlert - функція для підтвердження, що сповіщення переглянуто
+func AcknowledgeAlert(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	alert, err := findAccessibleAlert(c, db)
+	if alert == nil {
+		return err
+	}
+
+	if alert.AcknowledgedAt == nil {
+		user := middleware.CurrentUser(c)
+		now := time.Now()
+		if err := db.Model(alert).Updates(map[string]interface{}{
+			"acknowledged_at":    now,
+			"acknowledged_by_id": user.ID,
+		}).Error; err != nil {
+			log.Println("Error acknowledging alert:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Error acknowledging alert",
+			})
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Alert acknowledged successfully",
+		"alert":   alert,
+	})
+}
+
+// ResolveAlert - функція для ручного закриття сповіщення з необов'язковою приміткою
+func ResolveAlert(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Note string `json:"note"`
+	}
+	// Тіло запиту необов'язкове
+	_ = c.BodyParser(&requestData)
+
+	alert, err := findAccessibleAlert(c, db)
+	if alert == nil {
+		return err
+	}
+
+	if alert.Status == models.AlertStatusResolved {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Alert is already resolved",
+			"alert":   alert,
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":          models.AlertStatusResolved,
+		"resolved_at":     now,
+		"resolved_by_id":  user.ID,
+		"resolution_note": requestData.Note,
+	}
+	// Закриття вручну водночас означає, що сповіщення переглянуто
+	if alert.AcknowledgedAt == nil {
+		updates["acknowledged_at"] = now
+		updates["acknowledged_by_id"] = user.ID
+	}
+	if err := db.Model(alert).Updates(updates).Error; err != nil {
+		log.Println("Error resolving alert:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error resolving alert",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Alert resolved successfully",
+		"alert":   alert,
+	})
+}
+
+// findAccessibleAlert знаходить сповіщення за параметром :id, доступне поточному користувачу.
+// Пацієнт має доступ лише до власних сповіщень. Якщо сповіщення недоступне, надсилає відповідь і повертає nil.
+func findAccessibleAlert(c *fiber.Ctx, db *gorm.DB) (*models.Alert, error) {
+	var alert models.Alert
+	if err := db.First(&alert, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Alert not found",
+			})
+		}
+		log.Println("Error finding alert:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding alert",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if alert.PatientID != user.ID && !user.HasRole(models.RoleDoctor, models.RoleAdmin) {
+		return nil, middleware.Forbidden(c)
+	}
+	return &alert, nil
+}
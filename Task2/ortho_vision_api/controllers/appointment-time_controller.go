@@ -42,6 +42,13 @@ func CreateAppointmentTime(c *fiber.Ctx) error {
 		})
 	}
 
+	// Вільний час можна створювати лише лікарю з підтвердженим email
+	if !doctor.EmailVerified {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Doctor email must be verified before adding appointment times",
+		})
+	}
+
 	// Отримуємо дані з тіла запиту
 	var requestData struct {
 		AvailableTime string `json:"available_time"`
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"log"
+	"ortho_vision_api/middleware"
 	"ortho_vision_api/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,6 +18,12 @@ func CreateAppointment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid request")
 	}
 
+	// Пацієнт може записати на прийом лише себе
+	user := middleware.CurrentUser(c)
+	if user.Role == models.RolePatient {
+		appointment.PatientID = user.ID
+	}
+
 	// Перевіряємо, чи є доступний час
 	db, ok := c.Locals("db").(*gorm.DB)
 	if !ok || db == nil {
@@ -26,6 +33,25 @@ func CreateAppointment(c *fiber.Ctx) error {
 		})
 	}
 
+	// Записатися на прийом може лише пацієнт із підтвердженим email
+	var patient models.User
+	if err := db.First(&patient, "id = ? AND role = ?", appointment.PatientID, models.RolePatient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Patient not found",
+			})
+		}
+		log.Println("Error finding patient:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error verifying patient",
+		})
+	}
+	if !patient.EmailVerified {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Email must be verified before booking an appointment",
+		})
+	}
+
 	// Перевіряємо доступний час
 	var availableTime models.AppointmentTimes
 	err := db.Where("id = ? AND is_booked = ?", appointment.AppointmentTimeID, false).First(&availableTime).Error
@@ -94,6 +120,12 @@ func DeleteAppointment(c *fiber.Ctx) error {
 		})
 	}
 
+	// Пацієнт може скасувати лише власний запис
+	user := middleware.CurrentUser(c)
+	if user.Role == models.RolePatient && appointment.PatientID != user.ID {
+		return middleware.Forbidden(c)
+	}
+
 	// Оновлюємо статус часу на "available" (is_booked = false)
 	var availableTime models.AppointmentTimes
 	err = db.First(&availableTime, "id = ?", appointment.AppointmentTimeID).Error
@@ -1,14 +1,33 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net/mail"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/loginguard"
+	"ortho_vision_api/mailer"
+	"ortho_vision_api/middleware"
 	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// errEmailTaken — нова адреса email уже належить іншому користувачу.
+var errEmailTaken = errors.New("email is already taken")
+
+// isUniqueViolation перевіряє, чи помилка бази даних — порушення унікального індексу.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
 // RegisterUser - функція для реєстрації нового користувача
 func RegisterUser(c *fiber.Ctx) error {
 	// Отримуємо з'єднання з базою даних із контексту
@@ -20,6 +39,15 @@ func RegisterUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Отримуємо відправника листів із контексту
+	sender, ok := c.Locals("mailer").(mailer.Mailer)
+	if !ok || sender == nil {
+		log.Println("Mailer not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Mailer error",
+		})
+	}
+
 	// Створюємо нову структуру для користувача
 	var user models.User
 
@@ -31,6 +59,23 @@ func RegisterUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Перевірка формату email
+	address, err := mail.ParseAddress(user.Email)
+	if err != nil || address.Address != user.Email {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid email address",
+		})
+	}
+
+	// Самостійно можна зареєструватися лише як пацієнт; роль змінює адміністратор
+	user.Role = models.RolePatient
+
+	// Новий обліковий запис завжди потребує підтвердження email, 2FA підключається окремо
+	user.EmailVerified = false
+	user.TOTPEnabled = false
+	user.DeactivatedAt = nil
+	user.ClinicID = nil // Клініку вказують після реєстрації через оновлення профілю
+
 	// Перевірка на унікальність email
 	var existingUser models.User
 	if err := db.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
@@ -60,9 +105,14 @@ func RegisterUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Надсилаємо лист для підтвердження email
+	if err := sendVerificationEmail(db, sender, user); err != nil {
+		log.Println("Error sending verification email:", err)
+	}
+
 	// Відповідь про успішну реєстрацію
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "User registered successfully",
+		"message": "User registered successfully. Please check your email to verify your account",
 		"user":    user,
 	})
 }
@@ -78,6 +128,15 @@ func LoginUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Отримуємо захист від підбору пароля із контексту
+	guard, ok := c.Locals("login_guard").(*loginguard.Guard)
+	if !ok || guard == nil {
+		log.Println("Login guard not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Login guard error",
+		})
+	}
+
 	// Створюємо структуру для даних, які прийдуть у запиті
 	var loginData struct {
 		Email    string `json:"email"`
@@ -92,35 +151,170 @@ func LoginUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Резервуємо спробу ще до перевірки пароля, щоб паралельні запити не обійшли обмеження;
+	// спроба вважається невдалою, доки вхід не завершиться успішно
+	wait, err := guard.Attempt(loginData.Email, c.IP())
+	if err != nil {
+		log.Println("Login guard error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking login attempts",
+		})
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprintf("%d", int(wait.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"message": "Too many failed login attempts, try again later",
+		})
+	}
+
 	// Знаходимо користувача за email
 	var user models.User
 	if err := db.Where("email = ?", loginData.Email).First(&user).Error; err != nil {
 		log.Println("User not found:", err)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid email or password",
-		})
+		return loginFailed(c)
 	}
 
 	// Перевіряємо пароль
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(loginData.Password)); err != nil {
 		log.Println("Password mismatch")
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"message": "Invalid email or password",
+		return loginFailed(c)
+	}
+
+	// Успішний вхід скидає лічильник невдалих спроб облікового запису
+	if err := guard.Succeed(loginData.Email, c.IP()); err != nil {
+		log.Println("Login guard error:", err)
+	}
+
+	// Якщо увімкнено двофакторну автентифікацію, вхід завершується лише після перевірки коду
+	if user.TOTPEnabled {
+		return requireSecondFactor(c, user)
+	}
+
+	return completeLogin(c, db, user)
+}
+
+// accountDeactivated повертає відповідь для деактивованого облікового запису
+func accountDeactivated(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"message": "Account is deactivated",
+	})
+}
+
+// requireSecondFactor повертає токен другого кроку входу замість сесії
+func requireSecondFactor(c *fiber.Ctx, user models.User) error {
+	if !user.IsActive() {
+		return accountDeactivated(c)
+	}
+
+	mfaToken, err := auth.GenerateMFAToken(user)
+	if err != nil {
+		log.Println("Token signing error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error generating MFA token",
 		})
 	}
 
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":      "Two-factor authentication code required",
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+	})
+}
+
+// completeLogin створює нову сесію та повертає токени і дані користувача
+func completeLogin(c *fiber.Ctx, db *gorm.DB, user models.User) error {
+	if !user.IsActive() {
+		return accountDeactivated(c)
+	}
+
+	// Створюємо нову сесію: токен доступу з ID та роллю користувача і токен оновлення
+	tokens, err := auth.StartSession(db, user, c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		log.Println("Session creation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating session",
+		})
+	}
+
+	// Повідомляємо клієнту, якщо для ролі користувача 2FA обов'язкова, але ще не підключена
+	enrollmentRequired := false
+	if !user.TOTPEnabled {
+		required, err := auth.IsTwoFactorRequired(db, user.Role)
+		if err != nil {
+			log.Println("Error checking 2FA policy:", err)
+		}
+		enrollmentRequired = required
+	}
+
 	// Створюємо анонімну структуру для відповіді, щоб не включати PasswordHash
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Login successful",
+		"message":                 "Login successful",
+		"tokens":                  tokens,
+		"mfa_enrollment_required": enrollmentRequired,
 		"user": map[string]interface{}{
 			"id":    user.ID,
 			"email": user.Email,
 			"name":  user.Name,
+			"role":  user.Role,
 			// Додайте інші необхідні поля тут, які потрібно відправити у відповіді
 		},
 	})
 }
 
+// loginFailed повертає однакову для всіх причин відповідь на невдалу спробу входу
+// (спробу вже зараховано під час резервування)
+func loginFailed(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"message": "Invalid email or password",
+	})
+}
+
+// UnlockUser - функція для зняття блокування входу з облікового запису (для адміністратора)
+func UnlockUser(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	// Отримуємо захист від підбору пароля із контексту
+	guard, ok := c.Locals("login_guard").(*loginguard.Guard)
+	if !ok || guard == nil {
+		log.Println("Login guard not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Login guard error",
+		})
+	}
+
+	// Перевірка, чи існує користувач
+	var user models.User
+	if err := db.First(&user, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "User not found",
+			})
+		}
+		log.Println("Error finding user:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding user",
+		})
+	}
+
+	if err := guard.Unlock(user.Email); err != nil {
+		log.Println("Error unlocking user:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error unlocking user",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User unlocked successfully",
+	})
+}
+
 // UpdateUser - функція для оновлення даних користувача
 func UpdateUser(c *fiber.Ctx) error {
 	// Отримуємо з'єднання з базою даних із контексту
@@ -146,9 +340,12 @@ func UpdateUser(c *fiber.Ctx) error {
 
 	// Структура для отримання оновлених даних
 	var updatedData struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Name            string  `json:"name"`
+		Email           string  `json:"email"`
+		Password        string  `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+		ClinicID        *uint   `json:"clinic_id"`
+		Timezone        *string `json:"timezone"` // Порожній рядок — використовувати пояс клініки
 	}
 
 	// Парсимо тіло запиту
@@ -163,10 +360,60 @@ func UpdateUser(c *fiber.Ctx) error {
 	if updatedData.Name != "" {
 		user.Name = updatedData.Name
 	}
-	if updatedData.Email != "" {
+	emailChanged := false
+	if updatedData.Email != "" && updatedData.Email != user.Email {
+		// Перевірка формату email
+		address, err := mail.ParseAddress(updatedData.Email)
+		if err != nil || address.Address != updatedData.Email {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid email address",
+			})
+		}
+		// Нову адресу потрібно підтвердити знову
 		user.Email = updatedData.Email
+		user.EmailVerified = false
+		emailChanged = true
+	}
+	clinicChanged := false
+	if updatedData.ClinicID != nil {
+		// Перевіряємо, що клініка існує
+		var clinic models.Clinic
+		if err := db.First(&clinic, *updatedData.ClinicID).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Clinic not found",
+			})
+		}
+		clinicChanged = user.ClinicID == nil || *user.ClinicID != clinic.ID
+		user.ClinicID = &clinic.ID
+	}
+	if updatedData.Timezone != nil {
+		if *updatedData.Timezone != "" {
+			if _, err := telemetry.LoadTimezone(*updatedData.Timezone); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+		}
+		user.Timezone = *updatedData.Timezone
 	}
+	// Зміна пароля чи email потребує підтвердження поточним паролем: з новою адресою
+	// викрадений токен доступу дозволив би скинути пароль і заволодіти обліковим записом
+	if updatedData.Password != "" || emailChanged {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(updatedData.CurrentPassword)); err != nil {
+			log.Println("Current password mismatch")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Current password is incorrect",
+			})
+		}
+	}
+	passwordChanged := false
 	if updatedData.Password != "" {
+		if len(updatedData.Password) < minPasswordLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": fmt.Sprintf("Password must be at least %d characters long", minPasswordLength),
+			})
+		}
+
 		// Хешуємо новий пароль
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updatedData.Password), bcrypt.DefaultCost)
 		if err != nil {
@@ -176,16 +423,88 @@ func UpdateUser(c *fiber.Ctx) error {
 			})
 		}
 		user.PasswordHash = string(hashedPassword)
+		passwordChanged = true
 	}
 
-	// Зберігаємо оновлення в базу
-	if err := db.Save(&user).Error; err != nil {
+	// Зберігаємо оновлення в базу; з новою клінікою діють її порогові значення,
+	// тому агрегати показників пацієнта позначаємо для перебудови
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if emailChanged {
+			var taken int64
+			if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", user.Email, user.ID).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return errEmailTaken
+			}
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			// Адресу могли зайняти між перевіркою і збереженням
+			if emailChanged && isUniqueViolation(err) {
+				return errEmailTaken
+			}
+			return err
+		}
+		if emailChanged {
+			// Посилання, надіслані на попередню адресу, більше не діють
+			if err := tx.Model(&models.EmailVerificationToken{}).
+				Where("user_id = ? AND used_at IS NULL", user.ID).
+				Update("used_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		if passwordChanged {
+			// Завершуємо інші сесії, щоб викрадений токен оновлення перестав діяти;
+			// сесія, з якої змінено власний пароль, залишається
+			keepSessionID := ""
+			if middleware.CurrentUser(c).ID == user.ID {
+				keepSessionID = middleware.CurrentSessionID(c)
+			}
+			if err := auth.RevokeOtherSessions(tx, user.ID, keepSessionID); err != nil {
+				return err
+			}
+		}
+		if clinicChanged {
+			// Індивідуальні межі пацієнта разом із межами нової клініки не мають суперечити одна одній
+			thresholds, err := telemetry.ResolveThresholds(tx, user.ID)
+			if err != nil {
+				return err
+			}
+			if err := thresholds.Validate(); err != nil {
+				return err
+			}
+			return telemetry.InvalidatePatientRollups(tx, user.ID)
+		}
+		return nil
+	})
+	if err == telemetry.ErrResolvedLuxRange {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Patient thresholds conflict with the thresholds of the new clinic",
+		})
+	}
+	if err == errEmailTaken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "User with this email already exists",
+		})
+	}
+	if err != nil {
 		log.Println("Database save error:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Error updating user",
 		})
 	}
 
+	// Надсилаємо лист для підтвердження нової адреси
+	if emailChanged {
+		if sender, ok := c.Locals("mailer").(mailer.Mailer); ok && sender != nil {
+			if err := sendVerificationEmail(db, sender, user); err != nil {
+				log.Println("Error sending verification email:", err)
+			}
+		} else {
+			log.Println("Mailer not found in context")
+		}
+	}
+
 	// Відповідь про успішне оновлення
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "User updated successfully",
@@ -227,6 +546,25 @@ func DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Користувача з медичною історією не можна видалити — лише деактивувати
+	var historyCount int64
+	if err := db.Raw(`
+		SELECT
+			(SELECT COUNT(*) FROM appointments WHERE patient_id = ?) +
+			(SELECT COUNT(*) FROM appointment_times WHERE doctor_id = ?) +
+			(SELECT COUNT(*) FROM smartglassesdata WHERE user_id = ?)
+	`, user.ID, user.ID, user.ID).Scan(&historyCount).Error; err != nil {
+		log.Println("Error checking user history:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking user history",
+		})
+	}
+	if historyCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "User has appointments or telemetry history; deactivate the account instead",
+		})
+	}
+
 	// Видалення користувача
 	if err := db.Delete(&user).Error; err != nil {
 		log.Println("Error deleting user:", err)
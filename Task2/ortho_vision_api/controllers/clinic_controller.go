@@ -3,6 +3,7 @@ package controllers
 import (
 	"log"
 	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -37,6 +38,14 @@ func AddClinic(c *fiber.Ctx) error {
 			"message": "Name, Address, and Location are required fields",
 		})
 	}
+	// Часовий пояс необов'язковий, але якщо вказаний — має бути поясом IANA
+	if clinic.Timezone != "" {
+		if _, err := telemetry.LoadTimezone(clinic.Timezone); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+	}
 
 	// Заповнюємо поля часу створення та оновлення
 	clinic.CreatedAt = time.Now()
@@ -57,6 +66,60 @@ func AddClinic(c *fiber.Ctx) error {
 	})
 }
 
+// UpdateClinicTimezone - функція для зміни часового поясу клініки; порожнє значення повертає пояс за замовчуванням
+func UpdateClinicTimezone(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+	if requestData.Timezone != "" {
+		if _, err := telemetry.LoadTimezone(requestData.Timezone); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+	}
+
+	var clinic models.Clinic
+	if err := db.First(&clinic, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Clinic not found",
+			})
+		}
+		log.Println("Error finding clinic:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding clinic",
+		})
+	}
+
+	clinic.Timezone = requestData.Timezone
+	if err := db.Model(&clinic).Update("timezone", clinic.Timezone).Error; err != nil {
+		log.Println("Error updating clinic timezone:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error updating clinic timezone",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Clinic timezone updated successfully",
+		"clinic":  clinic,
+	})
+}
+
 // GetAllClinics - функція для отримання всіх клінік
 func GetAllClinics(c *fiber.Ctx) error {
 	// Отримуємо з'єднання з базою даних із контексту
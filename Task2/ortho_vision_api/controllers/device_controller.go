@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RegisterDevice - функція для реєстрації нових смарт-окулярів (для адміністратора).
+// API-ключ і код прив'язки повертаються лише в цій відповіді.
+func RegisterDevice(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		SerialNumber string `json:"serial_number"`
+	}
+	if err := c.BodyParser(&requestData); err != nil || strings.TrimSpace(requestData.SerialNumber) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Serial number is required",
+		})
+	}
+	serialNumber := strings.TrimSpace(requestData.SerialNumber)
+
+	// Перевіряємо, що пристрій із таким серійним номером ще не зареєстровано
+	var count int64
+	if err := db.Model(&models.SmartGlassesDevice{}).Where("serial_number = ?", serialNumber).Count(&count).Error; err != nil {
+		log.Println("Error checking device:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error registering device",
+		})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Device with this serial number already exists",
+		})
+	}
+
+	apiKey, err := auth.GenerateDeviceAPIKey()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error registering device",
+		})
+	}
+	pairingCode, err := auth.GeneratePairingCode()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error registering device",
+		})
+	}
+
+	expiresAt := time.Now().Add(config.DevicePairingCodeTTL)
+	device := models.SmartGlassesDevice{
+		SerialNumber:         serialNumber,
+		APIKeyHash:           auth.HashToken(apiKey),
+		PairingCodeHash:      auth.HashPairingCode(pairingCode),
+		PairingCodeExpiresAt: &expiresAt,
+	}
+	if err := db.Create(&device).Error; err != nil {
+		log.Println("Error creating device:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error registering device",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":                 "Device registered successfully",
+		"device":                  device,
+		"api_key":                 apiKey,
+		"pairing_code":            pairingCode,
+		"pairing_code_expires_at": expiresAt,
+	})
+}
+
+// GetAllDevices - функція для отримання всіх зареєстрованих пристроїв (для адміністратора)
+func GetAllDevices(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	query := db.Model(&models.SmartGlassesDevice{})
+	if patientID := c.Query("patient_id"); patientID != "" {
+		query = query.Where("patient_id = ?", patientID)
+	}
+
+	var devices []models.SmartGlassesDevice
+	if err := query.Order("id").Find(&devices).Error; err != nil {
+		log.Println("Error fetching devices:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching devices",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Devices retrieved successfully",
+		"data":    devices,
+	})
+}
+
+// IssuePairingCode - функція для видачі нового коду прив'язки пристрою (для адміністратора)
+func IssuePairingCode(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	device, err := findDeviceByParam(c, db)
+	if device == nil {
+		return err
+	}
+	if device.IsPaired() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Device is already paired, unpair it first",
+		})
+	}
+
+	pairingCode, err := auth.GeneratePairingCode()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error issuing pairing code",
+		})
+	}
+	expiresAt := time.Now().Add(config.DevicePairingCodeTTL)
+	if err := db.Model(device).Updates(map[string]interface{}{
+		"pairing_code_hash":       auth.HashPairingCode(pairingCode),
+		"pairing_code_expires_at": expiresAt,
+	}).Error; err != nil {
+		log.Println("Error saving pairing code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error issuing pairing code",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":                 "Pairing code issued successfully",
+		"pairing_code":            pairingCode,
+		"pairing_code_expires_at": expiresAt,
+	})
+}
+
+// RotateDeviceKey - функція для заміни API-ключа пристрою (для адміністратора).
+// Старий ключ одразу перестає діяти.
+func RotateDeviceKey(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	device, err := findDeviceByParam(c, db)
+	if device == nil {
+		return err
+	}
+
+	apiKey, err := auth.GenerateDeviceAPIKey()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error rotating device key",
+		})
+	}
+	if err := db.Model(device).Update("api_key_hash", auth.HashToken(apiKey)).Error; err != nil {
+		log.Println("Error saving device key:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error rotating device key",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device key rotated successfully",
+		"api_key": apiKey,
+	})
+}
+
+// DisableDevice - функція для вимкнення пристрою, наприклад у разі втрати (для адміністратора)
+func DisableDevice(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	device, err := findDeviceByParam(c, db)
+	if device == nil {
+		return err
+	}
+
+	if err := db.Model(device).Update("disabled_at", time.Now()).Error; err != nil {
+		log.Println("Error disabling device:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error disabling device",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device disabled successfully",
+		"device":  device,
+	})
+}
+
+// PairDevice - функція для прив'язки смарт-окулярів до пацієнта за серійним номером і кодом прив'язки
+func PairDevice(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		SerialNumber string `json:"serial_number"`
+		PairingCode  string `json:"pairing_code"`
+	}
+	if err := c.BodyParser(&requestData); err != nil || requestData.SerialNumber == "" || requestData.PairingCode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Serial number and pairing code are required",
+		})
+	}
+
+	// Пристрої прив'язуються лише до пацієнтів
+	user := middleware.CurrentUser(c)
+	if user.Role != models.RolePatient {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Only patients can pair devices",
+		})
+	}
+
+	// Прив'язуємо пристрій одним запитом, щоб код не можна було використати двічі
+	now := time.Now()
+	result := db.Model(&models.SmartGlassesDevice{}).
+		Where("serial_number = ? AND pairing_code_hash = ? AND pairing_code_expires_at > ? AND patient_id IS NULL AND disabled_at IS NULL",
+			strings.TrimSpace(requestData.SerialNumber), auth.HashPairingCode(requestData.PairingCode), now).
+		Updates(map[string]interface{}{
+			"patient_id":              user.ID,
+			"paired_at":               now,
+			"pairing_code_hash":       "",
+			"pairing_code_expires_at": nil,
+		})
+	if result.Error != nil {
+		log.Println("Error pairing device:", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error pairing device",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid serial number or pairing code",
+		})
+	}
+
+	var device models.SmartGlassesDevice
+	if err := db.Where("serial_number = ?", strings.TrimSpace(requestData.SerialNumber)).First(&device).Error; err != nil {
+		log.Println("Error finding device:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error pairing device",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device paired successfully",
+		"device":  device,
+	})
+}
+
+// GetMyDevices - функція для отримання пристроїв, прив'язаних до поточного користувача
+func GetMyDevices(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var devices []models.SmartGlassesDevice
+	if err := db.Where("patient_id = ?", middleware.CurrentUser(c).ID).Order("paired_at DESC").Find(&devices).Error; err != nil {
+		log.Println("Error fetching devices:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching devices",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Devices retrieved successfully",
+		"data":    devices,
+	})
+}
+
+// UnpairDevice - функція для відв'язки пристрою від пацієнта (власник пристрою або адміністратор).
+// Уже збережені показники залишаються в історії пацієнта.
+func UnpairDevice(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	device, err := findDeviceByParam(c, db)
+	if device == nil {
+		return err
+	}
+
+	user := middleware.CurrentUser(c)
+	isOwner := device.PatientID != nil && *device.PatientID == user.ID
+	if !isOwner && !user.HasRole(models.RoleAdmin) {
+		return middleware.Forbidden(c)
+	}
+
+	if err := db.Model(device).Updates(map[string]interface{}{
+		"patient_id": nil,
+		"paired_at":  nil,
+	}).Error; err != nil {
+		log.Println("Error unpairing device:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error unpairing device",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Device unpaired successfully",
+	})
+}
+
+// findDeviceByParam знаходить пристрій за параметром :id.
+// Якщо пристрій не знайдено, надсилає відповідь і повертає nil.
+func findDeviceByParam(c *fiber.Ctx, db *gorm.DB) (*models.SmartGlassesDevice, error) {
+	var device models.SmartGlassesDevice
+	if err := db.First(&device, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Device not found",
+			})
+		}
+		log.Println("Error finding device:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding device",
+		})
+	}
+	return &device, nil
+}
@@ -98,9 +98,9 @@ func UpdateDisease(c *fiber.Ctx) error {
 	})
 }
 
-// GetMedicalRecord - отримання всіх хвороб для призначення (appointment) за його ID
+// GetMedicalRecord - отримання всіх хвороб пацієнта за його ID
 func GetMedicalRecord(c *fiber.Ctx) error {
-	appointmentID := c.Params("appointmentID")
+	patientID := c.Params("patientID")
 
 	// Перевіряємо з'єднання з базою даних
 	db, ok := c.Locals("db").(*gorm.DB)
@@ -111,9 +111,11 @@ func GetMedicalRecord(c *fiber.Ctx) error {
 		})
 	}
 
-	// Отримуємо всі хвороби для призначення (appointment)
+	// Отримуємо всі хвороби з прийомів пацієнта
 	var diseases []models.Disease
-	if err := db.Where("appointment_id = ?", appointmentID).Find(&diseases).Error; err != nil {
+	if err := db.Joins("JOIN appointments a ON a.id = diseases.appointment_id").
+		Where("a.patient_id = ?", patientID).
+		Find(&diseases).Error; err != nil {
 		log.Println("Error fetching diseases:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": "Error fetching medical record",
@@ -122,7 +124,7 @@ func GetMedicalRecord(c *fiber.Ctx) error {
 
 	if len(diseases) == 0 {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"message": "No diseases found for the specified appointment",
+			"message": "No diseases found for the specified patient",
 		})
 	}
 
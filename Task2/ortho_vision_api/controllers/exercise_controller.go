@@ -0,0 +1,690 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/config"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// exerciseProgramRequest — дані програми вправ із тіла запиту
+type exerciseProgramRequest struct {
+	Name           string                `json:"name"`
+	Description    string                `json:"description"`
+	SessionsPerDay int                   `json:"sessions_per_day"`
+	Steps          []models.ExerciseStep `json:"steps"`
+}
+
+// CreateExerciseProgram - функція для створення програми світлодіодних вправ для очей (для лікаря й адміністратора)
+func CreateExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData exerciseProgramRequest
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	program := models.ExerciseProgram{
+		Name:           requestData.Name,
+		Description:    requestData.Description,
+		SessionsPerDay: requestData.SessionsPerDay,
+		Steps:          requestData.Steps,
+		CreatedByID:    middleware.CurrentUser(c).ID,
+	}
+	if err := telemetry.ValidateExerciseProgram(&program); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	// Кроки зберігаються разом із програмою
+	if err := db.Create(&program).Error; err != nil {
+		log.Println("Error saving exercise program:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error saving exercise program",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Exercise program created successfully",
+		"program": program,
+	})
+}
+
+// GetExercisePrograms - функція для отримання всіх актуальних програм вправ, без замінених версій (для лікаря й адміністратора)
+func GetExercisePrograms(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var programs []models.ExerciseProgram
+	if err := db.Preload("Steps", orderExerciseSteps).Where("superseded_by_id IS NULL").Order("name").Find(&programs).Error; err != nil {
+		log.Println("Error fetching exercise programs:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching exercise programs",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Exercise programs retrieved successfully",
+		"data":    programs,
+	})
+}
+
+// GetExerciseProgram - функція для отримання програми вправ за ID (для лікаря й адміністратора)
+func GetExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	program, err := findExerciseProgramByParam(c, db)
+	if program == nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Exercise program retrieved successfully",
+		"program": program,
+	})
+}
+
+// UpdateExerciseProgram - функція для зміни програми вправ; кроки програми замінюються повністю (для лікаря й адміністратора).
+// Якщо програму вже призначали пацієнтам, вона не змінюється: створюється нова версія, а активні
+// призначення переводяться на неї. Так історія виконання вправ рахується за програмою, що діяла тоді.
+func UpdateExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData exerciseProgramRequest
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	program, err := findExerciseProgramByParam(c, db)
+	if program == nil {
+		return err
+	}
+
+	updated := models.ExerciseProgram{
+		Name:           requestData.Name,
+		Description:    requestData.Description,
+		SessionsPerDay: requestData.SessionsPerDay,
+		Steps:          requestData.Steps,
+	}
+	if err := telemetry.ValidateExerciseProgram(&updated); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	versioned := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Блокуємо програму, щоб паралельні зміни не створили дві нові версії
+		var current models.ExerciseProgram
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, program.ID).Error; err != nil {
+			return err
+		}
+		if current.SupersededByID != nil {
+			program.SupersededByID = current.SupersededByID
+			return telemetry.ErrExerciseSuperseded
+		}
+
+		var assignments int64
+		if err := tx.Model(&models.ExerciseAssignment{}).Where("program_id = ?", program.ID).Count(&assignments).Error; err != nil {
+			return err
+		}
+
+		// Непризначену програму змінюємо на місці
+		if assignments == 0 {
+			for i := range updated.Steps {
+				updated.Steps[i].ProgramID = program.ID
+			}
+			if err := tx.Where("program_id = ?", program.ID).Delete(&models.ExerciseStep{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&updated.Steps).Error; err != nil {
+				return err
+			}
+			program.Name = updated.Name
+			program.Description = updated.Description
+			program.SessionsPerDay = updated.SessionsPerDay
+			program.Steps = updated.Steps
+			return tx.Model(program).Select("name", "description", "sessions_per_day").Updates(program).Error
+		}
+
+		// Призначену програму замінюємо новою версією
+		versioned = true
+		updated.CreatedByID = middleware.CurrentUser(c).ID
+		updated.PreviousVersionID = &program.ID
+		if err := tx.Create(&updated).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ExerciseProgram{}).Where("id = ?", program.ID).
+			Update("superseded_by_id", updated.ID).Error; err != nil {
+			return err
+		}
+
+		// Активні призначення завершуються і продовжуються новою версією з цього моменту
+		var active []models.ExerciseAssignment
+		if err := tx.Where("program_id = ? AND ended_at IS NULL", program.ID).Find(&active).Error; err != nil {
+			return err
+		}
+		if len(active) == 0 {
+			return nil
+		}
+		now := time.Now()
+		if err := tx.Model(&models.ExerciseAssignment{}).
+			Where("program_id = ? AND ended_at IS NULL", program.ID).
+			Update("ended_at", now).Error; err != nil {
+			return err
+		}
+		next := make([]models.ExerciseAssignment, 0, len(active))
+		for _, assignment := range active {
+			next = append(next, models.ExerciseAssignment{
+				PatientID:    assignment.PatientID,
+				ProgramID:    updated.ID,
+				AssignedByID: updated.CreatedByID,
+				Notes:        assignment.Notes,
+				StartedAt:    now,
+			})
+		}
+		return tx.Create(&next).Error
+	})
+	if err == telemetry.ErrExerciseSuperseded {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":          "Exercise program has been replaced by a newer version",
+			"superseded_by_id": program.SupersededByID,
+		})
+	}
+	if err != nil {
+		log.Println("Error updating exercise program:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error updating exercise program",
+		})
+	}
+
+	if versioned {
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message": "Exercise program has been assigned to patients, a new version was created",
+			"program": updated,
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Exercise program updated successfully",
+		"program": program,
+	})
+}
+
+// DeleteExerciseProgram - функція для видалення програми вправ, яку ще не призначали пацієнтам (для лікаря й адміністратора)
+func DeleteExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	program, err := findExerciseProgramByParam(c, db)
+	if program == nil {
+		return err
+	}
+
+	// Історія виконання посилається на призначення, тому призначену програму не видаляємо
+	var assignments int64
+	if err := db.Model(&models.ExerciseAssignment{}).Where("program_id = ?", program.ID).Count(&assignments).Error; err != nil {
+		log.Println("Error checking exercise assignments:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error deleting exercise program",
+		})
+	}
+	if assignments > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Exercise program has been assigned to patients and cannot be deleted",
+		})
+	}
+
+	if err := db.Delete(program).Error; err != nil {
+		log.Println("Error deleting exercise program:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error deleting exercise program",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Exercise program deleted successfully",
+	})
+}
+
+// AssignExerciseProgram - функція для призначення програми вправ пацієнтові; попереднє призначення завершується (для лікаря й адміністратора)
+func AssignExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	var requestData struct {
+		ProgramID uint   `json:"program_id"`
+		Notes     string `json:"notes"`
+	}
+	if err := c.BodyParser(&requestData); err != nil || requestData.ProgramID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "program_id is required",
+		})
+	}
+
+	var patient models.User
+	if err := db.First(&patient, patientID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Patient not found",
+			})
+		}
+		log.Println("Error finding patient:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error assigning exercise program",
+		})
+	}
+	if patient.Role != models.RolePatient {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Exercise programs can only be assigned to patients",
+		})
+	}
+
+	var program models.ExerciseProgram
+	if err := db.First(&program, requestData.ProgramID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Exercise program not found",
+			})
+		}
+		log.Println("Error finding exercise program:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error assigning exercise program",
+		})
+	}
+
+	if program.SupersededByID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":          "Exercise program has been replaced by a newer version",
+			"superseded_by_id": program.SupersededByID,
+		})
+	}
+
+	now := time.Now()
+	assignment := models.ExerciseAssignment{
+		PatientID:    patientID,
+		ProgramID:    program.ID,
+		AssignedByID: middleware.CurrentUser(c).ID,
+		Notes:        requestData.Notes,
+		StartedAt:    now,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ExerciseAssignment{}).
+			Where("patient_id = ? AND ended_at IS NULL", patientID).
+			Update("ended_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&assignment).Error
+	})
+	if err != nil {
+		log.Println("Error assigning exercise program:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error assigning exercise program",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":    "Exercise program assigned successfully",
+		"assignment": assignment,
+	})
+}
+
+// GetPatientExerciseProgram - функція для отримання активної програми вправ пацієнта
+func GetPatientExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	assignment, err := telemetry.ActiveExerciseAssignment(db, patientID)
+	if err != nil {
+		log.Println("Error fetching exercise assignment:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching exercise program",
+		})
+	}
+	if assignment == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "No exercise program is assigned",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":    "Exercise program retrieved successfully",
+		"assignment": assignment,
+	})
+}
+
+// EndExerciseProgram - функція для завершення активного призначення вправ пацієнта (для лікаря й адміністратора)
+func EndExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	result := db.Model(&models.ExerciseAssignment{}).
+		Where("patient_id = ? AND ended_at IS NULL", patientID).
+		Update("ended_at", time.Now())
+	if result.Error != nil {
+		log.Println("Error ending exercise assignment:", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error ending exercise program",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "No exercise program is assigned",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Exercise program ended successfully",
+	})
+}
+
+// GetExerciseAdherence - функція для отримання звіту про виконання вправ пацієнтом.
+// Параметри from і to (YYYY-MM-DD, включно); за замовчуванням — останні 7 діб.
+func GetExerciseAdherence(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	// Доби рахуються в часовому поясі пацієнта
+	location, err := telemetry.ResolveLocation(db, patientID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Patient not found",
+			})
+		}
+		log.Println("Error resolving timezone:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error computing exercise adherence",
+		})
+	}
+
+	year, month, day := time.Now().In(location).Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, location)
+	from := today.AddDate(0, 0, -6)
+	to := today
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid from date, expected YYYY-MM-DD",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, location)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid to date, expected YYYY-MM-DD",
+			})
+		}
+		to = parsed
+	}
+	// Дата to входить у період
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "from must not be later than to",
+		})
+	}
+	if to.Sub(from) > config.ExerciseAdherenceMaxRange {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Requested period is too long",
+		})
+	}
+
+	report, err := telemetry.ComputeExerciseAdherence(db, patientID, from, to)
+	if err != nil {
+		log.Println("Error computing exercise adherence:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error computing exercise adherence",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
+
+// GetDeviceExerciseProgram - функція для пристрою: активна програма вправ пацієнта з кроками
+func GetDeviceExerciseProgram(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
+		})
+	}
+
+	// Пристрій, автентифікований middleware.RequireDevice, завжди прив'язаний до пацієнта
+	device := middleware.CurrentDevice(c)
+
+	assignment, err := telemetry.ActiveExerciseAssignment(db, *device.PatientID)
+	if err != nil {
+		log.Println("Error fetching exercise assignment:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error fetching exercise program",
+		})
+	}
+	if assignment == nil {
+		// Пристрій опитує сервер регулярно, тому відсутність програми — не помилка
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"assignment_id":    assignment.ID,
+		"program_id":       assignment.Program.ID,
+		"name":             assignment.Program.Name,
+		"sessions_per_day": assignment.Program.SessionsPerDay,
+		"steps":            assignment.Program.Steps,
+		"updated_at":       assignment.Program.UpdatedAt,
+	})
+}
+
+// ReportExerciseCompletion - функція для пристрою: звіт про виконаний (або перерваний) сеанс вправ.
+// Повторно надісланий звіт про той самий сеанс не дублюється.
+func ReportExerciseCompletion(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
+		})
+	}
+
+	device := middleware.CurrentDevice(c)
+
+	var requestData struct {
+		AssignmentID   uint      `json:"assignment_id"`
+		StartedAt      time.Time `json:"started_at"`
+		FinishedAt     time.Time `json:"finished_at"`
+		StepsCompleted int       `json:"steps_completed"`
+	}
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request data",
+		})
+	}
+
+	now := time.Now()
+	if requestData.StartedAt.IsZero() || requestData.FinishedAt.Before(requestData.StartedAt) ||
+		requestData.FinishedAt.After(now.Add(config.TelemetryMaxClockSkew)) ||
+		requestData.StartedAt.Before(now.Add(-config.TelemetryMaxAge)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid started_at or finished_at",
+		})
+	}
+	if requestData.StepsCompleted < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "steps_completed must not be negative",
+		})
+	}
+
+	// Призначення має належати пацієнтові, до якого прив'язано пристрій
+	var assignment models.ExerciseAssignment
+	result := db.Where("id = ? AND patient_id = ?", requestData.AssignmentID, *device.PatientID).Limit(1).Find(&assignment)
+	if result.Error != nil {
+		log.Println("Error finding exercise assignment:", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error saving exercise completion",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Exercise assignment not found",
+		})
+	}
+
+	var steps int64
+	if err := db.Model(&models.ExerciseStep{}).Where("program_id = ?", assignment.ProgramID).Count(&steps).Error; err != nil {
+		log.Println("Error counting exercise steps:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error saving exercise completion",
+		})
+	}
+
+	completion := models.ExerciseCompletion{
+		AssignmentID:   assignment.ID,
+		PatientID:      assignment.PatientID,
+		DeviceID:       device.ID,
+		StartedAt:      requestData.StartedAt,
+		FinishedAt:     requestData.FinishedAt,
+		StepsCompleted: requestData.StepsCompleted,
+		Completed:      int64(requestData.StepsCompleted) >= steps,
+	}
+	created := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&completion)
+	if created.Error != nil {
+		log.Println("Error saving exercise completion:", created.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error saving exercise completion",
+		})
+	}
+	if created.RowsAffected == 0 {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Exercise completion already recorded",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":    "Exercise completion recorded",
+		"completion": completion,
+	})
+}
+
+// findExerciseProgramByParam знаходить програму вправ за параметром :id разом із кроками.
+// Якщо програму не знайдено, надсилає відповідь і повертає nil.
+func findExerciseProgramByParam(c *fiber.Ctx, db *gorm.DB) (*models.ExerciseProgram, error) {
+	var program models.ExerciseProgram
+	if err := db.Preload("Steps", orderExerciseSteps).First(&program, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Exercise program not found",
+			})
+		}
+		log.Println("Error finding exercise program:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding exercise program",
+		})
+	}
+	return &program, nil
+}
+
+// orderExerciseSteps впорядковує кроки програми під час завантаження
+func orderExerciseSteps(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"ortho_vision_api/config"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// exportRequest — параметри експорту з рядка запиту або тіла запиту.
+// Дати без часу (YYYY-MM-DD) означають північ у часовому поясі за замовчуванням;
+// дата в to включає весь цей день.
+type exportRequest struct {
+	Format     string `json:"format"`
+	Level      string `json:"level"`
+	PatientIDs []uint `json:"patient_ids"`
+	ClinicID   *uint  `json:"clinic_id"`
+	Disease    string `json:"disease"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+// ExportTelemetry - функція для потокового експорту показників когорти пацієнтів у CSV або Parquet.
+// Параметри: format=csv|parquet, level=raw|minute|hour, patient_ids (через кому), clinic_id, disease, from і to.
+// Період не може бути довшим за config.ExportStreamMaxRange — для довших періодів потрібно створити завдання POST /exports.
+func ExportTelemetry(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	requestData := exportRequest{
+		Format:  c.Query("format"),
+		Level:   c.Query("level"),
+		Disease: c.Query("disease"),
+		From:    c.Query("from"),
+		To:      c.Query("to"),
+	}
+	for _, value := range strings.Split(c.Query("patient_ids"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		patientID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || patientID == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid patient_ids",
+			})
+		}
+		requestData.PatientIDs = append(requestData.PatientIDs, uint(patientID))
+	}
+	if value := c.Query("clinic_id"); value != "" {
+		clinicID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || clinicID == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid clinic_id",
+			})
+		}
+		id := uint(clinicID)
+		requestData.ClinicID = &id
+	}
+
+	query, err := exportQuery(requestData, config.ExportStreamMaxRange)
+	if err != nil {
+		if err == telemetry.ErrRangeTooLarge {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Period is too long for a streaming export, create an export job instead",
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	// Рядки пишуться у відповідь у міру читання з бази даних; після початку передачі
+	// статус відповіді змінити вже не можна, тому помилки лише записуються в журнал
+	c.Set(fiber.HeaderContentType, query.ContentType())
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+query.FileName()+`"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if _, err := telemetry.Export(context.Background(), db, query, w); err != nil {
+			log.Println("Error streaming telemetry export:", err)
+		}
+		if err := w.Flush(); err != nil {
+			log.Println("Error streaming telemetry export:", err)
+		}
+	})
+	return nil
+}
+
+// CreateTelemetryExport - функція для створення фонового завдання експорту показників у файл.
+// Тіло запиту: format, level, patient_ids, clinic_id, disease, from, to. Стан завдання доступний
+// через GET /exports/:id, а готовий файл — через GET /exports/:id/download.
+func CreateTelemetryExport(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData exportRequest
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	query, err := exportQuery(requestData, config.ExportMaxRange)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	pseudonymKey, err := telemetry.NewPseudonymKey()
+	if err != nil {
+		log.Println("Error creating telemetry export:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating export job",
+		})
+	}
+
+	export := models.TelemetryExport{
+		RequestedByID: middleware.CurrentUser(c).ID,
+		PseudonymKey:  pseudonymKey,
+		Format:        query.Format,
+		Level:         query.Level,
+		PatientIDs:    query.PatientIDs,
+		ClinicID:      query.ClinicID,
+		Disease:       query.Disease,
+		From:          query.From,
+		To:            query.To,
+		Status:        models.ExportStatusPending,
+	}
+	if err := db.Create(&export).Error; err != nil {
+		log.Println("Error creating telemetry export:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating export job",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Export job created successfully",
+		"export":  export,
+	})
+}
+
+// GetTelemetryExports - функція для отримання завдань експорту.
+// Лікар бачить лише власні завдання, адміністратор — усі; підтримується пагінація page і page_size.
+func GetTelemetryExports(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	query := db.Model(&models.TelemetryExport{})
+	if !user.HasRole(models.RoleAdmin) {
+		query = query.Where("requested_by_id = ?", user.ID)
+	}
+
+	page, pageSize := pagination(c)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Error counting telemetry exports:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching export jobs",
+		})
+	}
+
+	var exports []models.TelemetryExport
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&exports).Error; err != nil {
+		log.Println("Error fetching telemetry exports:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching export jobs",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":   "Export jobs retrieved successfully",
+		"data":      exports,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// GetTelemetryExport - функція для отримання стану завдання експорту
+func GetTelemetryExport(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	export, err := findAccessibleExport(c, db)
+	if export == nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Export job retrieved successfully",
+		"export":  export,
+	})
+}
+
+// DownloadTelemetryExport - функція для завантаження файлу завершеного завдання експорту
+func DownloadTelemetryExport(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	export, err := findAccessibleExport(c, db)
+	if export == nil {
+		return err
+	}
+
+	if export.Status != models.ExportStatusCompleted {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Export file is not available",
+			"status":  export.Status,
+		})
+	}
+
+	query := telemetry.ExportQueryOf(export)
+	if err := c.Download(export.FilePath, query.FileName()); err != nil {
+		log.Println("Error sending export file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error sending export file",
+		})
+	}
+	c.Set(fiber.HeaderContentType, query.ContentType())
+	return nil
+}
+
+// DeleteTelemetryExport - функція для скасування завдання експорту або видалення готового файлу.
+// Завдання, що виконується, видалити не можна.
+func DeleteTelemetryExport(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	export, err := findAccessibleExport(c, db)
+	if export == nil {
+		return err
+	}
+
+	// Видаляємо лише завдання, яке фонова робота ще не взяла (або вже завершила)
+	result := db.Where("status <> ?", models.ExportStatusRunning).Delete(export)
+	if result.Error != nil {
+		log.Println("Error deleting telemetry export:", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error deleting export job",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Export job is running",
+		})
+	}
+
+	if err := telemetry.RemoveExportFile(export); err != nil {
+		log.Println("Error deleting export file:", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Export job deleted successfully",
+	})
+}
+
+// exportQuery перевіряє параметри експорту і перетворює їх на вибірку.
+// За замовчуванням експортуються сирі показники у CSV.
+func exportQuery(requestData exportRequest, maxRange time.Duration) (telemetry.ExportQuery, error) {
+	query := telemetry.ExportQuery{
+		Format:     requestData.Format,
+		Level:      requestData.Level,
+		PatientIDs: requestData.PatientIDs,
+		ClinicID:   requestData.ClinicID,
+		Disease:    strings.TrimSpace(requestData.Disease),
+	}
+	if query.Format == "" {
+		query.Format = telemetry.ExportFormatCSV
+	}
+	if query.Level == "" {
+		query.Level = telemetry.ExportLevelRaw
+	}
+
+	location, err := telemetry.LoadTimezone(config.DefaultTimezone)
+	if err != nil {
+		return query, err
+	}
+	if requestData.From == "" || requestData.To == "" {
+		return query, errors.New("Specify both from and to")
+	}
+	from, _, err := parseStatisticsTime(requestData.From, location)
+	if err != nil {
+		return query, errors.New("Invalid from. Use YYYY-MM-DD or RFC 3339.")
+	}
+	to, dateOnly, err := parseStatisticsTime(requestData.To, location)
+	if err != nil {
+		return query, errors.New("Invalid to. Use YYYY-MM-DD or RFC 3339.")
+	}
+	// Дата без часу в to включає весь цей день
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+	query.From, query.To = from, to
+
+	return query, query.Validate(maxRange)
+}
+
+// findAccessibleExport знаходить завдання експорту за параметром :id, доступне поточному користувачу.
+// Лікар має доступ лише до власних завдань. Якщо завдання недоступне, надсилає відповідь і повертає nil.
+func findAccessibleExport(c *fiber.Ctx, db *gorm.DB) (*models.TelemetryExport, error) {
+	var export models.TelemetryExport
+	if err := db.First(&export, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Export job not found",
+			})
+		}
+		log.Println("Error finding telemetry export:", err)
+		return nil, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding export job",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if export.RequestedByID != user.ID && !user.HasRole(models.RoleAdmin) {
+		return nil, middleware.Forbidden(c)
+	}
+	return &export, nil
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// StreamSmartGlassesData - функція для перегляду показників пацієнта в реальному часі (Server-Sent Events).
+// Кожен збережений показник надсилається подією "reading"; якщо клієнт не встигає читати,
+// частина показників відкидається і клієнт отримує подію "dropped" з їх кількістю.
+// З кожним службовим повідомленням перевіряється, що сесію не відкликано, а обліковий запис
+// активний; інакше клієнт отримує подію "closed" і потік закривається.
+func StreamSmartGlassesData(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	hub := telemetryHub(c)
+	if hub == nil {
+		log.Println("Telemetry hub not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Live stream is unavailable",
+		})
+	}
+
+	patientID, err := strconv.ParseUint(c.Params("patientID"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Вимикаємо буферизацію у проксі (nginx)
+
+	// Потік пишеться вже після виходу з обробника, тому дані сесії беремо заздалегідь
+	userID := middleware.CurrentUser(c).ID
+	sessionID := middleware.CurrentSessionID(c)
+
+	sub := hub.Subscribe(uint(patientID))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer hub.Unsubscribe(sub)
+
+		heartbeat := time.NewTicker(config.LiveStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		// Одразу надсилаємо коментар, щоб клієнт отримав заголовки
+		fmt.Fprint(w, ": connected\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case reading, ok := <-sub.Readings():
+				if !ok {
+					return
+				}
+				if dropped := sub.TakeDropped(); dropped > 0 {
+					fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", dropped)
+				}
+				body, err := json.Marshal(reading)
+				if err != nil {
+					log.Println("Live stream encoding error:", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: reading\nid: %d\ndata: %s\n\n", reading.ID, body)
+			case <-heartbeat.C:
+				// Відкликана сесія чи деактивований обліковий запис закривають потік так само,
+				// як RequireAuth відхилив би новий запит
+				if !liveStreamAllowed(db, userID, sessionID) {
+					fmt.Fprint(w, "event: closed\ndata: {\"reason\":\"session_ended\"}\n\n")
+					w.Flush()
+					return
+				}
+				// Службовий коментар; помилка запису означає, що клієнт відключився
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// liveStreamAllowed перевіряє, що користувач досі активний, а його сесію не відкликано.
+// Помилка бази даних теж закриває потік: клієнт перепідключиться і пройде звичайну автентифікацію.
+func liveStreamAllowed(db *gorm.DB, userID uint, sessionID string) bool {
+	var user models.User
+	if err := db.Select("id", "deactivated_at").First(&user, userID).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Println("Live stream error checking user:", err)
+		}
+		return false
+	}
+	if !user.IsActive() {
+		return false
+	}
+	active, err := auth.IsSessionActive(db, userID, sessionID)
+	if err != nil {
+		log.Println("Live stream error checking session:", err)
+		return false
+	}
+	return active
+}
+
+// telemetryHub повертає розсилку показників у реальному часі із контексту.
+func telemetryHub(c *fiber.Ctx) *telemetry.Hub {
+	hub, _ := c.Locals("telemetry_hub").(*telemetry.Hub)
+	return hub
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// oidcStateCookie — cookie, що прив'язує state до браузера, який почав вхід.
+const oidcStateCookie = "oidc_state"
+
+// errOIDCEmailNotVerified — провайдер не підтвердив email, тому обліковий запис не можна зв'язати або створити.
+var errOIDCEmailNotVerified = errors.New("oidc email is not verified")
+
+// OIDCLogin - функція для початку входу через OpenID Connect (перенаправлення до провайдера)
+func OIDCLogin(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	client, _ := c.Locals("oidc").(*auth.OIDCClient)
+	if client == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "OIDC sign-in is not configured",
+		})
+	}
+
+	// Генеруємо state, nonce і PKCE code verifier для цього входу
+	state, err := auth.GenerateRandomToken()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error starting OIDC sign-in",
+		})
+	}
+	nonce, err := auth.GenerateRandomToken()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error starting OIDC sign-in",
+		})
+	}
+	codeVerifier := oauth2.GenerateVerifier()
+
+	authURL, err := client.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		log.Println("OIDC provider error:", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"message": "OIDC provider is unavailable",
+		})
+	}
+
+	// Прострочені запити входу, які так і не завершилися, більше не потрібні
+	now := time.Now()
+	if err := db.Where("expires_at < ?", now).Delete(&models.OIDCLoginRequest{}).Error; err != nil {
+		log.Println("Error deleting expired OIDC login requests:", err)
+	}
+
+	// Зберігаємо дані входу до повернення користувача від провайдера
+	loginRequest := models.OIDCLoginRequest{
+		StateHash:    auth.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    now.Add(config.OIDCLoginTTL),
+	}
+	if err := db.Create(&loginRequest).Error; err != nil {
+		log.Println("Error saving OIDC login request:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error starting OIDC sign-in",
+		})
+	}
+
+	// Повернення від провайдера приймається лише в браузері з цим cookie,
+	// інакше чужий state і код могли б завершити вхід (login CSRF)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc",
+		Expires:  loginRequest.ExpiresAt,
+		Secure:   strings.HasPrefix(config.OIDCRedirectURL, "https://"),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode, // Lax, бо провайдер повертає користувача перенаправленням з іншого сайту
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// OIDCCallback - функція для завершення входу через OpenID Connect
+func OIDCCallback(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	client, _ := c.Locals("oidc").(*auth.OIDCClient)
+	if client == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "OIDC sign-in is not configured",
+		})
+	}
+
+	// Провайдер повідомив про помилку (наприклад, користувач скасував вхід)
+	if providerError := c.Query("error"); providerError != "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "OIDC sign-in failed: " + providerError,
+		})
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "State and code are required",
+		})
+	}
+
+	// state має збігатися з cookie браузера, який почав вхід
+	cookieState := c.Cookies(oidcStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/auth/oidc",
+		Expires:  time.Now().Add(-time.Hour), // Видаляємо cookie: state одноразовий
+		HTTPOnly: true,
+	})
+	if subtle.ConstantTimeCompare([]byte(cookieState), []byte(state)) != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid or expired OIDC state",
+		})
+	}
+
+	// Знаходимо та одразу видаляємо запит входу, щоб state не можна було використати повторно
+	var loginRequest models.OIDCLoginRequest
+	result := db.Where("state_hash = ?", auth.HashToken(state)).Limit(1).Find(&loginRequest)
+	if result.Error != nil {
+		log.Println("Error finding OIDC login request:", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error completing OIDC sign-in",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid or expired OIDC state",
+		})
+	}
+	deleted := db.Delete(&loginRequest)
+	if deleted.Error != nil {
+		log.Println("Error deleting OIDC login request:", deleted.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error completing OIDC sign-in",
+		})
+	}
+	// Якщо запис уже видалив паралельний запит або час вийшов — state недійсний
+	if deleted.RowsAffected == 0 || time.Now().After(loginRequest.ExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid or expired OIDC state",
+		})
+	}
+
+	// Обмінюємо код на токени та перевіряємо ID-токен
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	identity, err := client.Exchange(ctx, code, loginRequest.Nonce, loginRequest.CodeVerifier)
+	if err != nil {
+		log.Println("OIDC exchange error:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "OIDC sign-in failed",
+		})
+	}
+
+	user, err := findOrCreateOIDCUser(db, identity)
+	if err != nil {
+		if err == errOIDCEmailNotVerified {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "The provider did not confirm this email address",
+			})
+		}
+		log.Println("Error linking OIDC identity:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error completing OIDC sign-in",
+		})
+	}
+
+	// Далі вхід завершується так само, як при вході з паролем
+	if user.TOTPEnabled {
+		return requireSecondFactor(c, *user)
+	}
+	return completeLogin(c, db, *user)
+}
+
+// findOrCreateOIDCUser знаходить користувача, пов'язаного із зовнішнім обліковим записом.
+// Якщо зв'язку ще немає, пов'язує обліковий запис із користувачем з тим самим email
+// або створює нового пацієнта.
+func findOrCreateOIDCUser(db *gorm.DB, identity *auth.OIDCIdentity) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Зовнішній обліковий запис уже пов'язаний
+		var link models.ExternalIdentity
+		result := tx.Where("issuer = ? AND subject = ?", identity.Issuer, identity.Subject).Limit(1).Find(&link)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return tx.First(&user, link.UserID).Error
+		}
+
+		// Зв'язувати за email можна лише тоді, коли провайдер його підтвердив
+		if identity.Email == "" || !identity.EmailVerified {
+			return errOIDCEmailNotVerified
+		}
+
+		result = tx.Where("email = ?", identity.Email).Limit(1).Find(&user)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Створюємо нового пацієнта без пароля — вхід лише через провайдера або після скидання пароля
+			name := identity.Name
+			if name == "" {
+				name = identity.Email
+			}
+			user = models.User{
+				Name:          name,
+				Email:         identity.Email,
+				Role:          models.RolePatient,
+				EmailVerified: true,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		} else if !user.EmailVerified {
+			// Провайдер підтвердив адресу, тому вважаємо її підтвердженою і в нас
+			if err := tx.Model(&user).Update("email_verified", true).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.ExternalIdentity{
+			UserID:  user.ID,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
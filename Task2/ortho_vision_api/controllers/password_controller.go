@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/mailer"
+	"ortho_vision_api/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// minPasswordLength — мінімальна довжина нового пароля.
+const minPasswordLength = 8
+
+// errInvalidResetToken — токен скидання не знайдено, він прострочений або вже використаний.
+var errInvalidResetToken = errors.New("invalid reset token")
+
+// ForgotPassword - функція для надсилання листа зі скиданням пароля
+func ForgotPassword(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	// Отримуємо відправника листів із контексту
+	mail, ok := c.Locals("mailer").(mailer.Mailer)
+	if !ok || mail == nil {
+		log.Println("Mailer not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Mailer error",
+		})
+	}
+
+	var requestData struct {
+		Email string `json:"email"`
+	}
+
+	// Парсимо тіло запиту
+	if err := c.BodyParser(&requestData); err != nil || requestData.Email == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Email is required",
+		})
+	}
+
+	// Відповідь однакова незалежно від того, чи існує користувач,
+	// щоб не розкривати зареєстровані адреси
+	response := fiber.Map{
+		"message": "If an account with this email exists, a password reset link has been sent",
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", requestData.Email).First(&user).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Println("Error finding user:", err)
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+
+	// Створюємо одноразовий токен і зберігаємо лише його хеш
+	token, err := auth.GenerateRandomToken()
+	if err != nil {
+		log.Println("Token generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating password reset token",
+		})
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(config.PasswordResetTTL),
+	}
+	if err := db.Create(&resetToken).Error; err != nil {
+		log.Println("Error saving password reset token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error creating password reset token",
+		})
+	}
+
+	// Надсилаємо лист у фоні, щоб час відповіді не залежав від поштового сервера
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppBaseURL, url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Ortho Vision: password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo reset your password, open the link below:\n%s\n\nThe link is valid for %s and can be used only once. If you did not request a password reset, ignore this email.\n",
+			user.Name, link, config.PasswordResetTTL),
+	}
+	go func() {
+		if err := mail.Send(msg); err != nil {
+			log.Println("Error sending password reset email:", err)
+		}
+	}()
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// ResetPassword - функція для встановлення нового пароля за токеном скидання
+func ResetPassword(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	// Парсимо тіло запиту
+	if err := c.BodyParser(&requestData); err != nil || requestData.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Token and new password are required",
+		})
+	}
+
+	if len(requestData.NewPassword) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": fmt.Sprintf("Password must be at least %d characters long", minPasswordLength),
+		})
+	}
+
+	// Хешуємо новий пароль
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(requestData.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Password hashing error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error hashing password",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Шукаємо дійсний, ще не використаний токен
+		now := time.Now()
+		var resetToken models.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", auth.HashToken(requestData.Token), now).
+			First(&resetToken).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errInvalidResetToken
+			}
+			return err
+		}
+
+		// Позначаємо токен використаним; умова гарантує, що він спрацює лише один раз
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+
+		// Оновлюємо пароль
+		if err := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).
+			Update("password_hash", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+
+		// Інші токени скидання цього користувача більше не потрібні
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", resetToken.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		// Завершуємо всі сесії — після скидання потрібно увійти знову
+		return auth.RevokeAllSessions(tx, resetToken.UserID)
+	})
+	if err != nil {
+		if err == errInvalidResetToken {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid or expired reset token",
+			})
+		}
+		log.Println("Error resetting password:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error resetting password",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password reset successfully",
+	})
+}
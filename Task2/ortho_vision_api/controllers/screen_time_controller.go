@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/telemetry"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetScreenTimeReport - функція для отримання звіту про роботу на близькій відстані та дотримання перерв.
+// Параметри: period=day|week (за замовчуванням day), date=YYYY-MM-DD (за замовчуванням сьогодні).
+func GetScreenTimeReport(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	period := c.Query("period", telemetry.ScreenTimePeriodDay)
+	if period != telemetry.ScreenTimePeriodDay && period != telemetry.ScreenTimePeriodWeek {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Period must be 'day' or 'week'",
+		})
+	}
+
+	// Доби рахуються в часовому поясі пацієнта
+	location, err := telemetry.ResolveLocation(db, patientID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Patient not found",
+			})
+		}
+		log.Println("Error resolving timezone:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error computing screen time report",
+		})
+	}
+
+	day := time.Now().In(location)
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", date, location)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid date format, expected YYYY-MM-DD",
+			})
+		}
+		day = parsed
+	}
+
+	report, err := telemetry.ComputeScreenTime(db, patientID, period, day)
+	if err != nil {
+		log.Println("Error computing screen time:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error computing screen time report",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
+
+// GetBreakStatus - функція для пристрою: чи працює пацієнт зараз на близькій відстані і чи настав час перерви
+func GetBreakStatus(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
+		})
+	}
+
+	// Пристрій, автентифікований middleware.RequireDevice, завжди прив'язаний до пацієнта
+	device := middleware.CurrentDevice(c)
+
+	status, err := telemetry.CurrentBreakStatus(db, *device.PatientID, time.Now())
+	if err != nil {
+		log.Println("Error computing break status:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error computing break status",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(status)
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RefreshTokens - функція для оновлення пари токенів за токеном оновлення (з ротацією)
+func RefreshTokens(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// Парсимо тіло запиту
+	if err := c.BodyParser(&requestData); err != nil || requestData.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Refresh token is required",
+		})
+	}
+
+	// Перевіряємо токен і видаємо нову пару в межах тієї ж сесії
+	tokens, err := auth.RotateSession(db, requestData.RefreshToken, c.Get(fiber.HeaderUserAgent), c.IP())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			log.Println("Refresh token rejected:", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Invalid or expired refresh token",
+			})
+		}
+		log.Println("Error rotating refresh token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error refreshing tokens",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Tokens refreshed successfully",
+		"tokens":  tokens,
+	})
+}
+
+// Logout - функція для завершення поточної сесії
+func Logout(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if _, err := auth.RevokeSession(db, user.ID, middleware.CurrentSessionID(c)); err != nil {
+		log.Println("Error revoking session:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error logging out",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll - функція для завершення всіх сесій користувача на всіх пристроях
+func LogoutAll(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if err := auth.RevokeAllSessions(db, user.ID); err != nil {
+		log.Println("Error revoking sessions:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error logging out",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Logged out from all devices successfully",
+	})
+}
+
+// GetSessions - функція для отримання всіх активних сесій користувача
+func GetSessions(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	sessions, err := auth.ActiveSessions(db, user.ID)
+	if err != nil {
+		log.Println("Error fetching sessions:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching sessions",
+		})
+	}
+
+	// Позначаємо сесію, з якої зроблено запит
+	currentSessionID := middleware.CurrentSessionID(c)
+	response := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, fiber.Map{
+			"session_id":   session.SessionID,
+			"device":       session.Device,
+			"ip":           session.IP,
+			"started_at":   session.StartedAt,
+			"last_used_at": session.CreatedAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.SessionID == currentSessionID,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Sessions retrieved successfully",
+		"data":    response,
+	})
+}
+
+// RevokeSession - функція для відкликання однієї сесії користувача
+func RevokeSession(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	// Отримуємо ID сесії з параметрів
+	sessionID := c.Params("id")
+	if sessionID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Session ID is required",
+		})
+	}
+
+	// Відкликаємо лише сесію, що належить поточному користувачу
+	user := middleware.CurrentUser(c)
+	revoked, err := auth.RevokeSession(db, user.ID, sessionID)
+	if err != nil {
+		log.Println("Error revoking session:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error revoking session",
+		})
+	}
+	if !revoked {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Session not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Session revoked successfully",
+	})
+}
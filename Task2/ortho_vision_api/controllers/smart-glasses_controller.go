@@ -1,159 +1,332 @@
 package controllers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"ortho_vision_api/config"
+	"ortho_vision_api/middleware"
 	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
-// AddSmartGlassesData - Функція для додавання нових даних смарт-окулярів
+// AddSmartGlassesData - Функція для додавання нових даних смарт-окулярів.
+// Пристрій автентифікується API-ключем, а дані зберігаються для пацієнта, до якого він прив'язаний.
 func AddSmartGlassesData(c *fiber.Ctx) error {
-	// Завжди використовуємо user_id = 4
-	userID := 4
-
-	// Отримуємо дані з тіла запиту
-	var data models.SmartGlassesData
-	if err := c.BodyParser(&data); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid data",
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
 		})
 	}
 
-	// Перевірка наявності користувача в базі даних
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "User not found",
-			})
-		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch user",
+	// Пристрій, автентифікований middleware.RequireDevice
+	device := middleware.CurrentDevice(c)
+
+	// Отримуємо дані з тіла запиту (підтримуються всі версії формату показників)
+	data, err := telemetry.DecodeReading(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
-	// Встановлюємо дані для запису
-	data.UserID = uint(userID)
+	// Одиночний показник зберігається з часом сервера; показники з часом пристрою надсилаються пакетом
 	data.Timestamp = time.Now()
+	if err := telemetry.Validate(data, data.Timestamp); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	// Додаємо новий запис у таблицю
-	if err := config.DB.Create(&data).Error; err != nil {
+	readings := []models.SmartGlassesData{data}
+	duplicates, err := telemetry.Store(db, telemetryHub(c), device, readings)
+	if err != nil {
+		log.Println("Error creating smart glasses data:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create smart glasses data",
 		})
 	}
+	data = readings[0]
 
+	// Повтор уже збереженого показника приймається без помилки, але не зберігається вдруге
+	if duplicates[0] {
+		return c.Status(fiber.StatusOK).JSON(data)
+	}
 	return c.Status(fiber.StatusCreated).JSON(data)
 }
 
-// GetSmartGlassesStatistics - Функція для отримання статистики по даних смарт-окулярів за вказаний день
-func GetSmartGlassesStatistics(c *fiber.Ctx) error {
-	userID := 4
+// AddSmartGlassesDataBatch - Функція для пакетного додавання показників, накопичених пристроєм офлайн.
+// Приймає JSON-масив або NDJSON (Content-Type: application/x-ndjson); час кожного показника задає пристрій.
+// Коректні показники зберігаються одним запитом, для кожного елемента повертається результат.
+func AddSmartGlassesDataBatch(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
+		})
+	}
 
-	// Отримуємо дату з тіла запиту (потрібно, щоб дата була у форматі "YYYY-MM-DD")
-	dateParam := c.Query("date") // Читаємо параметр "date" з запиту
+	// Пристрій, автентифікований middleware.RequireDevice
+	device := middleware.CurrentDevice(c)
 
-	// Перевірка на коректність формату дати
-	date, err := time.Parse("2006-01-02", dateParam)
+	ndjson := strings.HasPrefix(c.Get(fiber.HeaderContentType), "application/x-ndjson")
+	items, err := telemetry.DecodeBatch(c.Body(), ndjson)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid date format. Use YYYY-MM-DD.",
+		status := fiber.StatusBadRequest
+		if err == telemetry.ErrBatchTooLarge {
+			status = fiber.StatusRequestEntityTooLarge
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
-	// Отримуємо дані з таблиці smart_glasses_data для заданого користувача
-	var data []models.SmartGlassesData
-	if err := config.DB.Where("user_id = ?", userID).Find(&data).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "No data found for the user",
+	results, err := telemetry.Ingest(db, telemetryHub(c), device, items)
+	if err != nil {
+		log.Println("Error creating smart glasses data batch:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create smart glasses data",
+		})
+	}
+
+	accepted, duplicates := 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case telemetry.StatusAccepted:
+			accepted++
+		case telemetry.StatusDuplicate:
+			duplicates++
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"accepted":   accepted,
+		"duplicates": duplicates,
+		"rejected":   len(results) - accepted - duplicates,
+		"results":    results,
+	})
+}
+
+// GetSequenceGaps - Функція для пристрою: пропущені діапазони номерів послідовності, які слід надіслати повторно.
+// Необов'язкові параметри: boot_id (нумерація, у якій шукати пропуски; порожній — показники без boot_id),
+// from і to (межі номерів, включно), limit (кількість пропусків у відповіді).
+func GetSequenceGaps(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
+		})
+	}
+
+	// Пристрій, автентифікований middleware.RequireDevice
+	device := middleware.CurrentDevice(c)
+
+	from, to := int64(0), int64(math.MaxInt64)
+	if value := c.Query("from"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "from must be a non-negative integer",
 			})
 		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < from {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "to must be an integer not less than from",
+			})
+		}
+		to = parsed
+	}
+	limit := c.QueryInt("limit", config.SequenceGapsDefaultLimit)
+	if limit < 1 {
+		limit = config.SequenceGapsDefaultLimit
+	}
+	if limit > config.SequenceGapsMaxLimit {
+		limit = config.SequenceGapsMaxLimit
+	}
+
+	report, err := telemetry.FindSequenceGaps(db, device.ID, c.Query("boot_id"), from, to, limit)
+	if err != nil {
+		log.Println("Error finding sequence gaps:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch smart glasses data",
+			"error": "Failed to find sequence gaps",
 		})
 	}
 
-	// Ініціалізація змінних для підрахунку часу
-	var timeHeadTiltExceeds45 float64
-	var timeLowLight float64
-	var timeHighLight float64
+	return c.Status(fiber.StatusOK).JSON(report)
+}
 
-	// Змінні для збереження часу початку порушення норми
-	var startHeadTiltExceeds45 time.Time
-	var startLowLight time.Time
-	var startHighLight time.Time
+// GetSmartGlassesStatistics - Функція для отримання статистики по даних смарт-окулярів.
+// Період задається параметром date (один день, YYYY-MM-DD) або парою from і to (YYYY-MM-DD або RFC 3339;
+// дата в to означає кінець цього дня). Параметр bucket (hour, day, week, month) додає розбивку за інтервалами.
+// Пацієнт отримує власну статистику, лікар і адміністратор вказують пацієнта параметром patient_id.
+func GetSmartGlassesStatistics(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database connection error",
+		})
+	}
 
-	// Перебираємо всі записи
-	for _, entry := range data {
-		// Перевіряємо, чи є дані для кожного запису
-		if entry.Timestamp.IsZero() || entry.PostureAngle == 0 || entry.EyeStrain == 0 {
-			continue // Пропускаємо цей запис, якщо є некоректні дані
-		}
+	userID, err := statisticsPatientID(c)
+	if userID == 0 {
+		return err
+	}
 
-		// Перевірка, чи потрапляє запис в заданий день
-		// Округлюємо час до початку дня і перевіряємо, чи він співпадає
-		entryDate := entry.Timestamp.Truncate(24 * time.Hour) // Округлюємо до початку дня
-		if entryDate != date {
-			continue // Пропускаємо записи, які не належать до вказаного дня
+	// Межі, що діють для пацієнта (індивідуальні, клініки або загальні)
+	thresholds, err := telemetry.ResolveThresholds(db, userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "User not found",
+			})
 		}
+		log.Println("Error resolving thresholds:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch thresholds",
+		})
+	}
 
-		// Якщо нахил голови більше 45°
-		if entry.PostureAngle > 45 {
-			// Якщо раніше не було початку порушення, фіксуємо його
-			if startHeadTiltExceeds45.IsZero() {
-				startHeadTiltExceeds45 = entry.Timestamp
-			}
-		} else {
-			// Якщо нахил голови став нормальним і був запис початку
-			if !startHeadTiltExceeds45.IsZero() {
-				duration := entry.Timestamp.Sub(startHeadTiltExceeds45).Seconds()
-				timeHeadTiltExceeds45 += duration
-				startHeadTiltExceeds45 = time.Time{} // Скидаємо початок порушення
-			}
-		}
+	// Доби рахуються в часовому поясі пацієнта (власному, клініки або за замовчуванням)
+	location, err := telemetry.ResolveLocation(db, userID)
+	if err != nil {
+		log.Println("Error resolving timezone:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to resolve timezone",
+		})
+	}
 
-		// Якщо освітлення менше 100 lux
-		if entry.EyeStrain < 100 {
-			// Якщо раніше не було початку порушення, фіксуємо його
-			if startLowLight.IsZero() {
-				startLowLight = entry.Timestamp
-			}
-		} else {
-			// Якщо освітлення стало нормальним і був запис початку
-			if !startLowLight.IsZero() {
-				duration := entry.Timestamp.Sub(startLowLight).Seconds()
-				timeLowLight += duration
-				startLowLight = time.Time{} // Скидаємо початок порушення
-			}
-		}
+	from, to, err := statisticsPeriod(c, location)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-		// Якщо освітлення більше 1000 lux
-		if entry.EyeStrain > 1000 {
-			// Якщо раніше не було початку порушення, фіксуємо його
-			if startHighLight.IsZero() {
-				startHighLight = entry.Timestamp
-			}
-		} else {
-			// Якщо освітлення стало нормальним і був запис початку
-			if !startHighLight.IsZero() {
-				duration := entry.Timestamp.Sub(startHighLight).Seconds()
-				timeHighLight += duration
-				startHighLight = time.Time{} // Скидаємо початок порушення
-			}
-		}
+	// Тривалість порушень рахується в базі даних
+	query := telemetry.StatsQuery{
+		PatientID:  userID,
+		From:       from,
+		To:         to,
+		Bucket:     c.Query("bucket"),
+		Thresholds: thresholds,
+		Location:   location,
+	}
+	if err := query.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	rows, source, err := telemetry.ComputeStats(db, query)
+	if err != nil {
+		log.Println("Error computing smart glasses statistics:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch smart glasses data",
+		})
 	}
 
-	// Створюємо відповідь з підрахованим часом
+	// Створюємо відповідь з підрахованим часом (у секундах)
+	total := telemetry.Total(rows)
 	stats := fiber.Map{
-		"time_head_tilt_exceeds_45": timeHeadTiltExceeds45,
-		"time_low_light":            timeLowLight,
-		"time_high_light":           timeHighLight,
+		"time_head_tilt_exceeded": total.TimeHeadTiltExceeded,
+		// Стара назва поля з часів фіксованого порогу 45°; залишена для наявних клієнтів
+		"time_head_tilt_exceeds_45": total.TimeHeadTiltExceeded,
+		"time_low_light":            total.TimeLowLight,
+		"time_high_light":           total.TimeHighLight,
+		"readings":                  total.Readings,
+		"thresholds":                thresholds,
+		"from":                      from.In(location),
+		"to":                        to.In(location),
+		"timezone":                  location.String(),
+		"source":                    source,
+	}
+	if query.Bucket != "" {
+		stats["bucket"] = query.Bucket
+		stats["buckets"] = rows
 	}
 
 	// Повертаємо результат
 	return c.Status(fiber.StatusOK).JSON(stats)
 }
+
+// statisticsPeriod читає період статистики з параметрів date або from і to.
+// Дати без часу означають північ у поясі location; доба триває від півночі до півночі
+// наступного дня, тому в дні переходу на літній час вона має 23 або 25 годин.
+func statisticsPeriod(c *fiber.Ctx, location *time.Location) (time.Time, time.Time, error) {
+	if dateParam := c.Query("date"); dateParam != "" {
+		date, err := time.ParseInLocation("2006-01-02", dateParam, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid date format. Use YYYY-MM-DD.")
+		}
+		return date, date.AddDate(0, 0, 1), nil
+	}
+
+	fromParam, toParam := c.Query("from"), c.Query("to")
+	if fromParam == "" || toParam == "" {
+		return time.Time{}, time.Time{}, errors.New("Specify date or both from and to")
+	}
+	from, _, err := parseStatisticsTime(fromParam, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid from. Use YYYY-MM-DD or RFC 3339.")
+	}
+	to, dateOnly, err := parseStatisticsTime(toParam, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid to. Use YYYY-MM-DD or RFC 3339.")
+	}
+	// Дата без часу в to включає весь цей день
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// parseStatisticsTime розбирає дату (YYYY-MM-DD, у поясі location) або час у форматі RFC 3339.
+func parseStatisticsTime(value string, location *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, location); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// statisticsPatientID визначає, чию статистику запитано.
+// Для пацієнта це завжди він сам; для лікаря й адміністратора — параметр patient_id.
+// Якщо пацієнта не вказано або доступ заборонено, надсилає відповідь і повертає 0.
+func statisticsPatientID(c *fiber.Ctx) (uint, error) {
+	user := middleware.CurrentUser(c)
+	patientParam := c.Query("patient_id")
+
+	if !user.HasRole(models.RoleDoctor, models.RoleAdmin) {
+		if patientParam != "" && !middleware.IsSelf(c, patientParam) {
+			return 0, middleware.Forbidden(c)
+		}
+		return user.ID, nil
+	}
+
+	patientID, err := strconv.ParseUint(patientParam, 10, 64)
+	if err != nil || patientID == 0 {
+		return 0, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "patient_id is required",
+		})
+	}
+	return uint(patientID), nil
+}
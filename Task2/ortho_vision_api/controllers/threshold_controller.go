@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"ortho_vision_api/telemetry"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetClinicThresholds - функція для отримання порогових значень клініки за замовчуванням (для адміністратора)
+func GetClinicThresholds(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	clinicID, ok := thresholdOwnerID(c, "id")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid clinic ID",
+		})
+	}
+
+	profile, err := findThresholdProfile(db, "clinic_id", clinicID)
+	if err != nil {
+		log.Println("Error fetching threshold profile:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching thresholds",
+		})
+	}
+	effective, err := telemetry.ResolveClinicThresholds(db, clinicID)
+	if err != nil {
+		log.Println("Error resolving thresholds:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching thresholds",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"profile":   profile,
+		"effective": effective,
+	})
+}
+
+// UpdateClinicThresholds - функція для встановлення порогових значень клініки за замовчуванням (для адміністратора)
+func UpdateClinicThresholds(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	clinicID, ok := thresholdOwnerID(c, "id")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid clinic ID",
+		})
+	}
+
+	// Перевіряємо, що клініка існує
+	var clinic models.Clinic
+	if err := db.First(&clinic, clinicID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Clinic not found",
+			})
+		}
+		log.Println("Error finding clinic:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding clinic",
+		})
+	}
+
+	return saveThresholdProfile(c, db, "clinic_id", clinicID)
+}
+
+// DeleteClinicThresholds - функція для скидання порогових значень клініки до загальних (для адміністратора)
+func DeleteClinicThresholds(c *fiber.Ctx) error {
+	return deleteThresholdProfile(c, "clinic_id", "id")
+}
+
+// GetPatientThresholds - функція для отримання порогових значень пацієнта
+// (індивідуальний профіль і межі, що фактично застосовуються)
+func GetPatientThresholds(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	profile, err := findThresholdProfile(db, "patient_id", patientID)
+	if err != nil {
+		log.Println("Error fetching threshold profile:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching thresholds",
+		})
+	}
+	effective, err := telemetry.ResolveThresholds(db, patientID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Patient not found",
+			})
+		}
+		log.Println("Error resolving thresholds:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching thresholds",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"profile":   profile,
+		"effective": effective,
+	})
+}
+
+// UpdatePatientThresholds - функція для встановлення індивідуальних порогових значень пацієнта (для лікаря або адміністратора)
+func UpdatePatientThresholds(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	patientID, ok := thresholdOwnerID(c, "patientID")
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid patient ID",
+		})
+	}
+
+	// Перевіряємо, що пацієнт існує
+	var patient models.User
+	if err := db.Where("id = ? AND role = ?", patientID, models.RolePatient).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Patient not found",
+			})
+		}
+		log.Println("Error finding patient:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding patient",
+		})
+	}
+
+	return saveThresholdProfile(c, db, "patient_id", patientID)
+}
+
+// DeletePatientThresholds - функція для видалення індивідуальних порогових значень пацієнта (для лікаря або адміністратора)
+func DeletePatientThresholds(c *fiber.Ctx) error {
+	return deleteThresholdProfile(c, "patient_id", "patientID")
+}
+
+// saveThresholdProfile створює або повністю замінює профіль власника (клініки чи пацієнта).
+func saveThresholdProfile(c *fiber.Ctx, db *gorm.DB, column string, ownerID uint) error {
+	var requestData struct {
+		MaxPostureAngle *float64 `json:"max_posture_angle"`
+		MinLux          *float64 `json:"min_lux"`
+		MaxLux          *float64 `json:"max_lux"`
+	}
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	profile, err := findThresholdProfile(db, column, ownerID)
+	if err != nil {
+		log.Println("Error fetching threshold profile:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error saving thresholds",
+		})
+	}
+	if profile == nil {
+		profile = &models.ThresholdProfile{}
+		if column == "clinic_id" {
+			profile.ClinicID = &ownerID
+		} else {
+			profile.PatientID = &ownerID
+		}
+	}
+
+	profile.MaxPostureAngle = requestData.MaxPostureAngle
+	profile.MinLux = requestData.MinLux
+	profile.MaxLux = requestData.MaxLux
+	profile.UpdatedByID = middleware.CurrentUser(c).ID
+
+	if err := telemetry.ValidateThresholdProfile(*profile); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	// Агрегати показників пораховано зі старими межами, тому разом із профілем позначаємо їх для перебудови
+	var conflicts []uint
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(profile).Error; err != nil {
+			return err
+		}
+		// Межі перевіряються такими, якими вони діятимуть: разом з успадкованими значеннями
+		var err error
+		if conflicts, err = checkResolvedThresholds(tx, column, ownerID); err != nil {
+			return err
+		}
+		return invalidateThresholdRollups(tx, column, ownerID)
+	})
+	if err == telemetry.ErrResolvedLuxRange {
+		return thresholdConflict(c, conflicts)
+	}
+	if err != nil {
+		log.Println("Error saving threshold profile:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error saving thresholds",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Thresholds saved successfully",
+		"profile": profile,
+	})
+}
+
+// deleteThresholdProfile видаляє профіль власника, після чого діють успадковані межі.
+func deleteThresholdProfile(c *fiber.Ctx, column, param string) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	ownerID, ok := thresholdOwnerID(c, param)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid ID",
+		})
+	}
+
+	// Без профілю клініки до індивідуальних профілів її пацієнтів застосовуються загальні межі
+	var conflicts []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(column+" = ?", ownerID).Delete(&models.ThresholdProfile{}).Error; err != nil {
+			return err
+		}
+		if column == "clinic_id" {
+			var err error
+			if conflicts, err = checkResolvedThresholds(tx, column, ownerID); err != nil {
+				return err
+			}
+		}
+		return invalidateThresholdRollups(tx, column, ownerID)
+	})
+	if err == telemetry.ErrResolvedLuxRange {
+		return thresholdConflict(c, conflicts)
+	}
+	if err != nil {
+		log.Println("Error deleting threshold profile:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error deleting thresholds",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Thresholds deleted successfully",
+	})
+}
+
+// checkResolvedThresholds перевіряє межі, що діятимуть після зміни профілю. Для клініки перевіряються
+// також її пацієнти з індивідуальними профілями; ID пацієнтів із суперечливими межами повертаються
+// разом із помилкою telemetry.ErrResolvedLuxRange.
+func checkResolvedThresholds(db *gorm.DB, column string, ownerID uint) ([]uint, error) {
+	if column != "clinic_id" {
+		effective, err := telemetry.ResolveThresholds(db, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, effective.Validate()
+	}
+
+	effective, err := telemetry.ResolveClinicThresholds(db, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := effective.Validate(); err != nil {
+		return nil, err
+	}
+	conflicts, err := telemetry.ConflictingClinicPatients(db, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return conflicts, telemetry.ErrResolvedLuxRange
+	}
+	return nil, nil
+}
+
+// thresholdConflict надсилає відповідь про суперечливі межі. Якщо суперечність виникає в пацієнтів
+// клініки, повертається 409 з їхніми ID — спершу потрібно змінити їхні індивідуальні профілі.
+func thresholdConflict(c *fiber.Ctx, patientIDs []uint) error {
+	if len(patientIDs) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":     telemetry.ErrResolvedLuxRange.Error(),
+			"patient_ids": patientIDs,
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"message": telemetry.ErrResolvedLuxRange.Error(),
+	})
+}
+
+// invalidateThresholdRollups позначає для перебудови агрегати пацієнтів, яких стосується профіль.
+func invalidateThresholdRollups(db *gorm.DB, column string, ownerID uint) error {
+	if column == "clinic_id" {
+		return telemetry.InvalidateClinicRollups(db, ownerID)
+	}
+	return telemetry.InvalidatePatientRollups(db, ownerID)
+}
+
+// findThresholdProfile повертає профіль за власником або nil, якщо профілю немає.
+func findThresholdProfile(db *gorm.DB, column string, ownerID uint) (*models.ThresholdProfile, error) {
+	var profile models.ThresholdProfile
+	result := db.Where(column+" = ?", ownerID).Limit(1).Find(&profile)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &profile, nil
+}
+
+// thresholdOwnerID читає ID клініки або пацієнта з параметра маршруту.
+func thresholdOwnerID(c *fiber.Ctx, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Params(param), 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
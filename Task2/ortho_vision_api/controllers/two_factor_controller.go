@@ -0,0 +1,432 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/loginguard"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// LoginTwoFactor - функція для завершення входу кодом TOTP або кодом відновлення
+func LoginTwoFactor(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	// Отримуємо захист від підбору пароля із контексту
+	guard, ok := c.Locals("login_guard").(*loginguard.Guard)
+	if !ok || guard == nil {
+		log.Println("Login guard not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Login guard error",
+		})
+	}
+
+	var requestData struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	// Парсимо тіло запиту
+	if err := c.BodyParser(&requestData); err != nil || requestData.MFAToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "MFA token and code are required",
+		})
+	}
+
+	// Перевіряємо токен, виданий після перевірки пароля
+	claims, err := auth.ParseMFAToken(requestData.MFAToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired MFA token",
+		})
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired MFA token",
+		})
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Invalid or expired MFA token",
+			})
+		}
+		log.Println("Error finding user:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding user",
+		})
+	}
+
+	// Підбір кодів обмежується так само, як і підбір пароля: спробу резервуємо до перевірки коду
+	wait, err := guard.Attempt(user.Email, c.IP())
+	if err != nil {
+		log.Println("Login guard error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking login attempts",
+		})
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprintf("%d", int(wait.Seconds())+1))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"message": "Too many failed login attempts, try again later",
+		})
+	}
+
+	// Перевіряємо код TOTP або одноразовий код відновлення
+	valid := false
+	if requestData.RecoveryCode != "" {
+		valid, err = auth.UseRecoveryCode(db, user.ID, requestData.RecoveryCode)
+		if err != nil {
+			log.Println("Error checking recovery code:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Error checking recovery code",
+			})
+		}
+	} else if user.TOTPEnabled {
+		valid, err = auth.UseTOTP(db, &user, requestData.Code)
+		if err != nil {
+			log.Println("Error checking TOTP code:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Error checking two-factor authentication code",
+			})
+		}
+	}
+
+	if !valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid two-factor authentication code",
+		})
+	}
+
+	if err := guard.Succeed(user.Email, c.IP()); err != nil {
+		log.Println("Login guard error:", err)
+	}
+
+	return completeLogin(c, db, user)
+}
+
+// SetupTwoFactor - функція для початку підключення TOTP (створює секрет і URI для QR-коду)
+func SetupTwoFactor(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+
+	// Створюємо новий секрет; 2FA увімкнеться лише після підтвердження кодом
+	key, err := auth.NewTOTPKey(user.Email)
+	if err != nil {
+		log.Println("TOTP key generation error:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error generating TOTP secret",
+		})
+	}
+
+	// Новий секрет — нова послідовність кодів, тому використані коди старого секрету забуваються
+	if err := db.Model(user).Updates(map[string]interface{}{
+		"totp_secret":    key.Secret(),
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		log.Println("Error saving TOTP secret:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error saving TOTP secret",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":          "Scan the QR code with an authenticator app and confirm with a code",
+		"secret":           key.Secret(),
+		"provisioning_uri": key.URL(),
+	})
+}
+
+// EnableTwoFactor - функція для підтвердження підключення TOTP першим кодом
+func EnableTwoFactor(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&requestData); err != nil || requestData.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Code is required",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is already enabled",
+		})
+	}
+	if user.TOTPSecret == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Start two-factor setup first",
+		})
+	}
+	valid, err := auth.UseTOTP(db, user, requestData.Code)
+	if err != nil {
+		log.Println("Error checking TOTP code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking two-factor authentication code",
+		})
+	}
+	if !valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid two-factor authentication code",
+		})
+	}
+
+	if err := db.Model(user).Update("totp_enabled", true).Error; err != nil {
+		log.Println("Error enabling 2FA:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error enabling two-factor authentication",
+		})
+	}
+
+	// Видаємо коди відновлення — користувач бачить їх лише один раз
+	codes, err := auth.ReplaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		log.Println("Error generating recovery codes:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error generating recovery codes",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor - функція для вимкнення TOTP (потрібні пароль і поточний код)
+func DisableTwoFactor(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+
+	// Якщо 2FA обов'язкова для ролі, вимкнути її не можна
+	required, err := auth.IsTwoFactorRequired(db, user.Role)
+	if err != nil {
+		log.Println("Error checking 2FA policy:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking two-factor policy",
+		})
+	}
+	if required {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Two-factor authentication is mandatory for your role",
+		})
+	}
+
+	// Код перевіряється лише після пароля, щоб невірний пароль не витрачав дійсний код
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(requestData.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid password or two-factor authentication code",
+		})
+	}
+	valid, err := auth.UseTOTP(db, user, requestData.Code)
+	if err != nil {
+		log.Println("Error checking TOTP code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking two-factor authentication code",
+		})
+	}
+	if !valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid password or two-factor authentication code",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    nil,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.TOTPRecoveryCode{}).Error
+	})
+	if err != nil {
+		log.Println("Error disabling 2FA:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error disabling two-factor authentication",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes - функція для створення нових кодів відновлення (старі перестають діяти)
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var requestData struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&requestData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if !user.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Two-factor authentication is not enabled",
+		})
+	}
+	valid, err := auth.UseTOTP(db, user, requestData.Code)
+	if err != nil {
+		log.Println("Error checking TOTP code:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking two-factor authentication code",
+		})
+	}
+	if !valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid two-factor authentication code",
+		})
+	}
+
+	codes, err := auth.ReplaceRecoveryCodes(db, user.ID)
+	if err != nil {
+		log.Println("Error generating recovery codes:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error generating recovery codes",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":        "Recovery codes regenerated",
+		"recovery_codes": codes,
+	})
+}
+
+// GetTwoFactorPolicies - функція для отримання політики обов'язкової 2FA за ролями (для адміністратора)
+func GetTwoFactorPolicies(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var policies []models.TwoFactorPolicy
+	if err := db.Order("role").Find(&policies).Error; err != nil {
+		log.Println("Error fetching 2FA policies:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching two-factor policies",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor policies retrieved successfully",
+		"data":    policies,
+	})
+}
+
+// UpdateTwoFactorPolicy - функція для встановлення обов'язковості 2FA для ролі (для адміністратора)
+func UpdateTwoFactorPolicy(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var policy models.TwoFactorPolicy
+	if err := c.BodyParser(&policy); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request data",
+		})
+	}
+
+	if policy.Role != models.RolePatient && policy.Role != models.RoleDoctor && policy.Role != models.RoleAdmin {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Role must be one of: patient, doctor, admin",
+		})
+	}
+
+	// Save створює запис для ролі або оновлює наявний
+	if err := db.Save(&policy).Error; err != nil {
+		log.Println("Error saving 2FA policy:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error saving two-factor policy",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Two-factor policy updated successfully",
+		"data":    policy,
+	})
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/mailer"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// sendVerificationEmail створює токен підтвердження та надсилає користувачу лист із посиланням
+func sendVerificationEmail(db *gorm.DB, mail mailer.Mailer, user models.User) error {
+	// Створюємо одноразовий токен і зберігаємо лише його хеш
+	token, err := auth.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	verificationToken := models.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(config.EmailVerificationTTL),
+	}
+	if err := db.Create(&verificationToken).Error; err != nil {
+		return err
+	}
+
+	// Надсилаємо лист у фоні, щоб час відповіді не залежав від поштового сервера
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppBaseURL, url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Ortho Vision: confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo confirm your email address, open the link below:\n%s\n\nThe link is valid for %s.\n",
+			user.Name, link, config.EmailVerificationTTL),
+	}
+	go func() {
+		if err := mail.Send(msg); err != nil {
+			log.Println("Error sending verification email:", err)
+		}
+	}()
+
+	return nil
+}
+
+// VerifyEmail - функція для підтвердження електронної адреси за токеном із листа
+func VerifyEmail(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Verification token is required",
+		})
+	}
+
+	verified := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Шукаємо дійсний, ще не використаний токен
+		now := time.Now()
+		var verificationToken models.EmailVerificationToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", auth.HashToken(token), now).
+			First(&verificationToken).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+
+		// Позначаємо використаними всі токени користувача, щоб старі посилання перестали діяти
+		if err := tx.Model(&models.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", verificationToken.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		// Токен, надісланий на попередню адресу, нову адресу не підтверджує
+		result := tx.Model(&models.User{}).Where("id = ? AND email = ?", verificationToken.UserID, verificationToken.Email).
+			Update("email_verified", true)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		verified = result.RowsAffected > 0
+		return nil
+	})
+	if err != nil {
+		log.Println("Error verifying email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error verifying email",
+		})
+	}
+
+	if !verified {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid or expired verification token",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerificationEmail - функція для повторного надсилання листа з підтвердженням
+func ResendVerificationEmail(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	// Отримуємо відправника листів із контексту
+	mail, ok := c.Locals("mailer").(mailer.Mailer)
+	if !ok || mail == nil {
+		log.Println("Mailer not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Mailer error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.EmailVerified {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Email is already verified",
+		})
+	}
+
+	// Обмежуємо частоту надсилання за вже створеними токенами
+	var last models.EmailVerificationToken
+	err := db.Where("user_id = ?", user.ID).Order("created_at DESC").First(&last).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Println("Error checking verification tokens:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error sending verification email",
+		})
+	}
+	if err == nil {
+		if wait := config.VerificationResendInterval - time.Since(last.CreatedAt); wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, fmt.Sprintf("%d", int(wait.Seconds())+1))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": "Please wait before requesting another verification email",
+			})
+		}
+	}
+
+	var sentLastHour int64
+	if err := db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-time.Hour)).
+		Count(&sentLastHour).Error; err != nil {
+		log.Println("Error checking verification tokens:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error sending verification email",
+		})
+	}
+	if sentLastHour >= int64(config.VerificationResendPerHour) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"message": "Too many verification emails requested, try again later",
+		})
+	}
+
+	if err := sendVerificationEmail(db, mail, *user); err != nil {
+		log.Println("Error sending verification email:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error sending verification email",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Verification email sent",
+	})
+}
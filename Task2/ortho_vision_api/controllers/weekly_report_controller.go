@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"log"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetWeeklyReports - функція для отримання щотижневих звітів про тенденції постави й освітлення.
+// Пацієнт бачить лише власні звіти; лікар і адміністратор можуть фільтрувати за patient_id.
+// Параметр deteriorated=true залишає лише звіти зі значущим погіршенням; підтримується пагінація page і page_size.
+func GetWeeklyReports(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	query := db.Model(&models.WeeklyReport{})
+	if user.HasRole(models.RoleDoctor, models.RoleAdmin) {
+		if patientID := c.Query("patient_id"); patientID != "" {
+			query = query.Where("patient_id = ?", patientID)
+		}
+	} else {
+		query = query.Where("patient_id = ?", user.ID)
+	}
+	if c.QueryBool("deteriorated") {
+		query = query.Where("posture_deteriorated OR lighting_deteriorated")
+	}
+
+	page, pageSize := pagination(c)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Println("Error counting weekly reports:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching weekly reports",
+		})
+	}
+
+	var reports []models.WeeklyReport
+	if err := query.Order("week_start DESC, patient_id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&reports).Error; err != nil {
+		log.Println("Error fetching weekly reports:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error fetching weekly reports",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":   "Weekly reports retrieved successfully",
+		"data":      reports,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// GetWeeklyReport - функція для отримання одного щотижневого звіту
+func GetWeeklyReport(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	var report models.WeeklyReport
+	if err := db.First(&report, c.Params("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Weekly report not found",
+			})
+		}
+		log.Println("Error finding weekly report:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding weekly report",
+		})
+	}
+
+	user := middleware.CurrentUser(c)
+	if report.PatientID != user.ID && !user.HasRole(models.RoleDoctor, models.RoleAdmin) {
+		return middleware.Forbidden(c)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Weekly report retrieved successfully",
+		"report":  report,
+	})
+}
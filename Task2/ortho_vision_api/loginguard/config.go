@@ -0,0 +1,34 @@
+package loginguard
+
+import (
+	"ortho_vision_api/config"
+
+	"gorm.io/gorm"
+)
+
+// NewFromConfig створює Guard зі сховищем і правилами відповідно до налаштувань.
+func NewFromConfig(db *gorm.DB) *Guard {
+	var store Store
+	switch config.LoginGuardStore {
+	case "postgres":
+		store = NewPostgresStore(db)
+	default:
+		store = NewMemoryStore(config.LoginFailureWindow)
+	}
+
+	account := Policy{
+		MaxFailures: config.LoginAccountMaxFailures,
+		Lockout:     config.LoginAccountLockout,
+		BaseDelay:   config.LoginBaseDelay,
+		MaxDelay:    config.LoginMaxDelay,
+		Window:      config.LoginFailureWindow,
+	}
+	ip := Policy{
+		MaxFailures: config.LoginIPMaxFailures,
+		Lockout:     config.LoginIPLockout,
+		BaseDelay:   config.LoginIPBaseDelay,
+		MaxDelay:    config.LoginIPMaxDelay,
+		Window:      config.LoginFailureWindow,
+	}
+	return New(store, account, ip)
+}
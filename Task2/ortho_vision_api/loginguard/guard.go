@@ -0,0 +1,126 @@
+package loginguard
+
+import (
+	"strings"
+	"time"
+)
+
+// State — стан лічильника невдалих спроб для одного ключа.
+type State struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store — сховище лічильників невдалих спроб входу.
+type Store interface {
+	// Get повертає поточний стан ключа (нульовий, якщо спроб не було).
+	Get(key string) (State, error)
+	// Reserve атомарно перевіряє, що ключ не заблоковано і затримка після попередньої спроби минула,
+	// і одразу зараховує спробу як невдалу (блокуючи ключ, якщо досягнуто policy.MaxFailures).
+	// Якщо остання невдала спроба була раніше за now-window, лічильник починається заново.
+	// Повертає новий стан і true або поточний стан і false, якщо спробу не дозволено.
+	Reserve(key string, now time.Time, policy Policy) (State, bool, error)
+	// Release скасовує зарезервовану спробу, що виявилася успішною,
+	// разом із блокуванням, яке ця спроба встановила.
+	Release(key string, policy Policy) error
+	// Reset видаляє лічильник ключа.
+	Reset(key string) error
+}
+
+// Policy — правила обмеження для одного типу ключа.
+type Policy struct {
+	MaxFailures int           // Кількість невдалих спроб до блокування
+	Lockout     time.Duration // Тривалість блокування
+	BaseDelay   time.Duration // Затримка після першої невдалої спроби, далі подвоюється
+	MaxDelay    time.Duration // Максимальна затримка між спробами
+	Window      time.Duration // Час без невдалих спроб, після якого лічильник обнуляється
+}
+
+// Guard обмежує спроби входу окремо для облікового запису та для IP-адреси.
+type Guard struct {
+	store   Store
+	account Policy
+	ip      Policy
+	now     func() time.Time
+}
+
+// New створює Guard зі сховищем і правилами для облікових записів та IP-адрес.
+func New(store Store, account, ip Policy) *Guard {
+	return &Guard{store: store, account: account, ip: ip, now: time.Now}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt резервує спробу входу і повертає, скільки ще потрібно чекати перед нею.
+// Нуль означає, що спробу дозволено; вона вже зарахована як невдала, тож паралельні запити
+// не можуть обійти блокування чи затримку. Після успішної перевірки слід викликати Succeed.
+func (g *Guard) Attempt(email, ip string) (time.Duration, error) {
+	// Спершу IP-адреса: відхилена спроба з неї не витрачає ліміт облікового запису
+	if wait, err := g.reserve(ipKey(ip), g.ip); wait > 0 || err != nil {
+		return wait, err
+	}
+	return g.reserve(accountKey(email), g.account)
+}
+
+// Succeed скидає лічильник облікового запису після успішного входу і скасовує зарезервовану
+// спробу IP-адреси. Попередні невдалі спроби IP-адреси не скидаються, щоб один відомий пароль
+// не відкривав подальший перебір.
+func (g *Guard) Succeed(email, ip string) error {
+	if err := g.store.Reset(accountKey(email)); err != nil {
+		return err
+	}
+	return g.store.Release(ipKey(ip), g.ip)
+}
+
+// Unlock знімає блокування з облікового запису (дія адміністратора).
+func (g *Guard) Unlock(email string) error {
+	return g.store.Reset(accountKey(email))
+}
+
+func (g *Guard) reserve(key string, policy Policy) (time.Duration, error) {
+	now := g.now()
+	state, reserved, err := g.store.Reserve(key, now, policy)
+	if err != nil || reserved {
+		return 0, err
+	}
+	// Спробу могли відхилити через блокування, яке вже минуло до цього моменту
+	return max(policy.wait(state, now), time.Nanosecond), nil
+}
+
+// wait повертає, скільки ще потрібно чекати перед наступною спробою для ключа зі станом state.
+func (p Policy) wait(state State, now time.Time) time.Duration {
+	if state.LockedUntil.After(now) {
+		return state.LockedUntil.Sub(now)
+	}
+	if state.Failures == 0 || now.Sub(state.LastFailure) > p.Window {
+		return 0
+	}
+
+	next := state.LastFailure.Add(p.delay(state.Failures))
+	if next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// delay повертає затримку після failures невдалих спроб: BaseDelay * 2^(failures-1), не більше MaxDelay.
+func (p Policy) delay(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(d, p.MaxDelay)
+}
@@ -0,0 +1,107 @@
+package loginguard
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAttemptConcurrentRequests(t *testing.T) {
+	account := Policy{MaxFailures: 5, Lockout: time.Minute, Window: time.Minute}
+	ip := Policy{MaxFailures: 100, Lockout: time.Minute, Window: time.Minute}
+	guard := New(NewMemoryStore(time.Minute), account, ip)
+
+	// Без затримки паралельні запити можуть пройти лише до MaxFailures спроб облікового запису
+	var allowed int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := guard.Attempt("user@example.com", "10.0.0.1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != account.MaxFailures {
+		t.Fatalf("allowed %d attempts, want %d", allowed, account.MaxFailures)
+	}
+}
+
+func TestAttemptBackoffAndSucceed(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	account := Policy{MaxFailures: 5, Lockout: time.Minute, BaseDelay: time.Second, MaxDelay: 8 * time.Second, Window: time.Hour}
+	ip := Policy{MaxFailures: 3, Lockout: time.Minute, BaseDelay: 250 * time.Millisecond, MaxDelay: time.Second, Window: time.Hour}
+	guard := New(NewMemoryStore(time.Hour), account, ip)
+	guard.now = func() time.Time { return now }
+
+	attempt := func(email string) time.Duration {
+		t.Helper()
+		wait, err := guard.Attempt(email, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return wait
+	}
+
+	if wait := attempt("a@example.com"); wait != 0 {
+		t.Fatalf("first attempt must be allowed, got wait %v", wait)
+	}
+	// Затримка IP-адреси діє і для іншого облікового запису
+	if wait := attempt("b@example.com"); wait != 250*time.Millisecond {
+		t.Fatalf("wait = %v, want IP backoff 250ms", wait)
+	}
+
+	now = now.Add(250 * time.Millisecond)
+	if wait := attempt("b@example.com"); wait != 0 {
+		t.Fatalf("attempt after IP backoff must be allowed, got wait %v", wait)
+	}
+	if err := guard.Succeed("b@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Успішна спроба не зараховується IP-адресі: третя невдала спроба ще не блокує її
+	now = now.Add(time.Second)
+	if wait := attempt("a@example.com"); wait != 0 {
+		t.Fatalf("wait = %v, want 0", wait)
+	}
+	now = now.Add(time.Second)
+	if wait := attempt("c@example.com"); wait != 0 {
+		t.Fatalf("wait = %v, want 0", wait)
+	}
+	// Третя спроба вже під час резервування заблокувала IP-адресу
+	now = now.Add(time.Second)
+	if wait := attempt("d@example.com"); wait != time.Minute-time.Second {
+		t.Fatalf("wait = %v, want rest of IP lockout", wait)
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package loginguard
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore зберігає лічильники в пам'яті процесу.
+// Підходить лише для одного екземпляра сервера.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]State
+	ttl     time.Duration
+}
+
+// NewMemoryStore створює MemoryStore. Записи, до яких не зверталися довше за ttl
+// і які не заблоковані, періодично видаляються.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	s := &MemoryStore{entries: make(map[string]State), ttl: ttl}
+	go s.cleanup()
+	return s
+}
+
+// Get повертає поточний стан ключа.
+func (s *MemoryStore) Get(key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+// Reserve перевіряє і зараховує спробу під одним блокуванням.
+func (s *MemoryStore) Reserve(key string, now time.Time, policy Policy) (State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.entries[key]
+	if policy.wait(state, now) > 0 {
+		return state, false, nil
+	}
+	if now.Sub(state.LastFailure) > policy.Window {
+		state.Failures = 0
+	}
+	state.Failures++
+	state.LastFailure = now
+	if state.Failures >= policy.MaxFailures {
+		state.LockedUntil = now.Add(policy.Lockout)
+	}
+	s.entries[key] = state
+	return state, true, nil
+}
+
+// Release скасовує зарезервовану спробу.
+func (s *MemoryStore) Release(key string, policy Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.entries[key]
+	if !ok || state.Failures == 0 {
+		return nil
+	}
+	state.Failures--
+	if state.Failures < policy.MaxFailures {
+		state.LockedUntil = time.Time{}
+	}
+	s.entries[key] = state
+	return nil
+}
+
+// Reset видаляє лічильник ключа.
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// cleanup періодично видаляє застарілі записи, щоб пам'ять не росла безмежно.
+func (s *MemoryStore) cleanup() {
+	ticker := time.NewTicker(s.ttl)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.mu.Lock()
+		for key, state := range s.entries {
+			if now.Sub(state.LastFailure) > s.ttl && now.After(state.LockedUntil) {
+				delete(s.entries, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,97 @@
+package loginguard
+
+import (
+	"ortho_vision_api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresStore зберігає лічильники в таблиці login_attempts,
+// тому обмеження спільні для всіх реплік сервера.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore створює PostgresStore.
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Get повертає поточний стан ключа.
+func (s *PostgresStore) Get(key string) (State, error) {
+	var attempt models.LoginAttempt
+	err := s.db.Where("attempt_key = ?", key).Limit(1).Find(&attempt).Error
+	if err != nil {
+		return State{}, err
+	}
+	return toState(attempt), nil
+}
+
+// Reserve атомарно перевіряє і зараховує спробу одним запитом INSERT ... ON CONFLICT DO UPDATE ... WHERE.
+// Якщо умова WHERE не виконується, рядок не змінюється і RETURNING нічого не повертає.
+func (s *PostgresStore) Reserve(key string, now time.Time, policy Policy) (State, bool, error) {
+	var attempts []models.LoginAttempt
+	err := s.db.Raw(`
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at, locked_until)
+		VALUES (@key, 1, @now, CASE WHEN 1 >= @max_failures THEN CAST(@locked_until AS timestamptz) END)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < @window_start THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			locked_until = CASE
+				WHEN (CASE WHEN login_attempts.last_failure_at < @window_start THEN 1 ELSE login_attempts.failures + 1 END) >= @max_failures
+				THEN CAST(@locked_until AS timestamptz)
+				ELSE login_attempts.locked_until
+			END
+		WHERE (login_attempts.locked_until IS NULL OR login_attempts.locked_until <= @now)
+			AND (login_attempts.last_failure_at < @window_start OR login_attempts.failures = 0
+				OR login_attempts.last_failure_at + LEAST(@base_delay * POWER(2, login_attempts.failures - 1), @max_delay) * INTERVAL '1 second' <= @now)
+		RETURNING attempt_key, failures, last_failure_at, locked_until
+	`, map[string]interface{}{
+		"key":          key,
+		"now":          now,
+		"window_start": now.Add(-policy.Window),
+		"max_failures": policy.MaxFailures,
+		"locked_until": now.Add(policy.Lockout),
+		"base_delay":   max(policy.BaseDelay, 0).Seconds(),
+		"max_delay":    max(policy.MaxDelay, 0).Seconds(),
+	}).Scan(&attempts).Error
+	if err != nil {
+		return State{}, false, err
+	}
+	if len(attempts) > 0 {
+		return toState(attempts[0]), true, nil
+	}
+
+	state, err := s.Get(key)
+	return state, false, err
+}
+
+// Release скасовує зарезервовану спробу.
+func (s *PostgresStore) Release(key string, policy Policy) error {
+	return s.db.Exec(`
+		UPDATE login_attempts SET
+			failures = failures - 1,
+			locked_until = CASE WHEN failures - 1 < ? THEN NULL ELSE locked_until END
+		WHERE attempt_key = ? AND failures > 0
+	`, policy.MaxFailures, key).Error
+}
+
+// Reset видаляє лічильник ключа.
+func (s *PostgresStore) Reset(key string) error {
+	return s.db.Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func toState(attempt models.LoginAttempt) State {
+	state := State{
+		Failures:    attempt.Failures,
+		LastFailure: attempt.LastFailureAt,
+	}
+	if attempt.LockedUntil != nil {
+		state.LockedUntil = *attempt.LockedUntil
+	}
+	return state
+}
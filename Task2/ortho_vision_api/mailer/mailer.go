@@ -0,0 +1,27 @@
+package mailer
+
+import (
+	"ortho_vision_api/config"
+)
+
+// Message — лист, який потрібно відправити.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer — інтерфейс для відправлення листів.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New створює реалізацію Mailer відповідно до налаштувань.
+func New() Mailer {
+	switch config.MailerDriver {
+	case "smtp":
+		return NewSMTPMailer(config.SMTPHost, config.SMTPPort, config.SMTPUser, config.SMTPPassword, config.MailFrom)
+	default:
+		return NewLogMailer(config.MailLogFile)
+	}
+}
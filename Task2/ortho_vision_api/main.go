@@ -1,32 +1,123 @@
 package main
 
 import (
+	"context"
 	"log"
+	"ortho_vision_api/auth"
 	"ortho_vision_api/config"
+	"ortho_vision_api/loginguard"
+	"ortho_vision_api/mailer"
 	"ortho_vision_api/routes"
+	"ortho_vision_api/telemetry"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // База часових поясів у бінарному файлі, щоб не залежати від системної
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func main() {
+	// Без секрету для підпису токенів сервер не запускається
+	if err := config.CheckJWTSecret(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
 	// Налаштування підключення до БД
 	_ = config.InitDB()
 
 	// Створення нового серверу на Fiber
 	app := fiber.New()
 
+	// Створюємо відправника листів відповідно до налаштувань
+	mail := mailer.New()
+
+	// Створюємо захист від підбору пароля
+	guard := loginguard.NewFromConfig(config.DB)
+
+	// Створюємо клієнт OIDC (nil, якщо вхід через провайдера не налаштовано)
+	oidcClient := auth.NewOIDCClient(config.OIDCIssuer, config.OIDCClientID, config.OIDCClientSecret, config.OIDCRedirectURL)
+
+	// Перевіряємо часовий пояс за замовчуванням, у якому рахується добова статистика
+	if _, err := telemetry.LoadTimezone(config.DefaultTimezone); err != nil {
+		log.Fatal("Invalid DEFAULT_TIMEZONE: ", err)
+	}
+
+	// Створюємо розсилку показників смарт-окулярів у реальному часі
+	hub := telemetry.NewHub(config.LiveStreamBuffer)
+
+	// Запускаємо перевірку показників на тривалі порушення
+	alerts := telemetry.NewAlertEngine(config.DB)
+	if err := alerts.Start(); err != nil {
+		log.Fatal("Error starting alert engine: ", err)
+	}
+	defer alerts.Stop()
+	hub.AddListener(alerts.Enqueue)
+
+	// Запускаємо агрегацію показників і очищення застарілих даних
+	rollups := telemetry.NewRollupJob(config.DB)
+	rollups.Start()
+	defer rollups.Stop()
+
+	// Запускаємо аналіз тенденцій і створення щотижневих звітів
+	trends := telemetry.NewTrendJob(config.DB)
+	trends.Start()
+	defer trends.Stop()
+
+	// Запускаємо виконання фонових завдань експорту показників
+	exports := telemetry.NewExportJob(config.DB)
+	if err := exports.Start(); err != nil {
+		// Без log.Fatal, щоб відкладені зупинки вже запущених завдань виконалися
+		log.Println("Error starting export job:", err)
+		return
+	}
+	defer exports.Stop()
+
+	// Запускаємо отримання показників смарт-окулярів через MQTT (якщо брокер налаштовано)
+	if subscriber := telemetry.NewSubscriberFromConfig(config.DB, hub); subscriber != nil {
+		subscriber.Start()
+		defer subscriber.Stop()
+	}
+
 	app.Use(func(c *fiber.Ctx) error {
 		// Додаємо з'єднання з базою даних у контекст
 		c.Locals("db", config.DB)
+		// Додаємо відправника листів у контекст
+		c.Locals("mailer", mail)
+		// Додаємо захист від підбору пароля у контекст
+		c.Locals("login_guard", guard)
+		// Додаємо клієнт OIDC у контекст
+		c.Locals("oidc", oidcClient)
+		// Додаємо розсилку показників у реальному часі у контекст
+		c.Locals("telemetry_hub", hub)
 		return c.Next()
 	})
 
 	// Налаштування маршрутів
 	routes.SetupRoutes(app)
 
+	// Зупиняємо сервер за сигналом SIGINT або SIGTERM; після цього main завершується звичайно
+	// і відкладені виклики Stop() коректно зупиняють фонові завдання
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Запуск сервера
-	err := app.Listen(":3000")
-	if err != nil {
-		log.Fatal("Error starting server: ", err)
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(":3000")
+	}()
+
+	select {
+	case err := <-listenErr:
+		if err != nil {
+			log.Println("Error starting server:", err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down server...")
+		// Відкриті потоки показників у реальному часі не мають затримувати зупинку безкінечно
+		if err := app.ShutdownWithTimeout(10 * time.Second); err != nil {
+			log.Println("Error shutting down server:", err)
+		}
 	}
 }
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RequireAuth перевіряє токен доступу із заголовка Authorization
+// і зберігає автентифікованого користувача в c.Locals("user").
+func RequireAuth(c *fiber.Ctx) error {
+	// Отримуємо токен із заголовка "Authorization: Bearer <token>"
+	header := c.Get(fiber.HeaderAuthorization)
+	tokenString, found := strings.CutPrefix(header, "Bearer ")
+	if !found || tokenString == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Missing or malformed access token",
+		})
+	}
+
+	// Перевіряємо підпис і термін дії токена
+	claims, err := auth.ParseAccessToken(tokenString)
+	if err != nil {
+		log.Println("Access token error:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired access token",
+		})
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		log.Println("Access token error:", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid or expired access token",
+		})
+	}
+
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	// Перевіряємо, що користувач із токена досі існує
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "User no longer exists",
+			})
+		}
+		log.Println("Error finding user:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error finding user",
+		})
+	}
+
+	// Деактивований користувач не має доступу навіть із ще дійсним токеном
+	if !user.IsActive() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Account is deactivated",
+		})
+	}
+
+	// Перевіряємо, що сесію, з якої видано токен, не було відкликано
+	active, err := auth.IsSessionActive(db, user.ID, claims.SessionID)
+	if err != nil {
+		log.Println("Error checking session:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking session",
+		})
+	}
+	if !active {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Session has been revoked",
+		})
+	}
+
+	c.Locals("user", &user)
+	c.Locals("session_id", claims.SessionID)
+	return c.Next()
+}
+
+// CurrentUser повертає автентифікованого користувача, збереженого RequireAuth.
+func CurrentUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals("user").(*models.User)
+	return user
+}
+
+// CurrentSessionID повертає ID сесії, з якої видано поточний токен доступу.
+func CurrentSessionID(c *fiber.Ctx) string {
+	sessionID, _ := c.Locals("session_id").(string)
+	return sessionID
+}
+
+// RequireTwoFactorEnrollment не пропускає користувачів, для ролі яких 2FA обов'язкова,
+// доки вони не підключать TOTP. Маршрути підключення 2FA реєструються до цього middleware.
+func RequireTwoFactorEnrollment(c *fiber.Ctx) error {
+	user := CurrentUser(c)
+	if user == nil || user.TOTPEnabled {
+		return c.Next()
+	}
+
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	required, err := auth.IsTwoFactorRequired(db, user.Role)
+	if err != nil {
+		log.Println("Error checking 2FA policy:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error checking two-factor policy",
+		})
+	}
+	if required {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Two-factor authentication must be enabled for your role",
+		})
+	}
+	return c.Next()
+}
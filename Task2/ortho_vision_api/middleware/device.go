@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// DeviceKeyHeader — заголовок, у якому смарт-окуляри передають свій API-ключ.
+const DeviceKeyHeader = "X-Device-Key"
+
+// RequireDevice перевіряє API-ключ пристрою і зберігає пристрій у c.Locals("device").
+// Пристрій, не прив'язаний до пацієнта або прив'язаний до деактивованого пацієнта, не може надсилати дані.
+func RequireDevice(c *fiber.Ctx) error {
+	// Отримуємо з'єднання з базою даних із контексту
+	db, ok := c.Locals("db").(*gorm.DB)
+	if !ok || db == nil {
+		log.Println("Database connection not found in context")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Database connection error",
+		})
+	}
+
+	device, err := auth.AuthenticateDevice(db, c.Get(DeviceKeyHeader))
+	if err != nil {
+		if err == auth.ErrInvalidDeviceKey {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Invalid or missing device key",
+			})
+		}
+		log.Println("Error authenticating device:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error authenticating device",
+		})
+	}
+
+	if !device.IsPaired() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Device is not paired with a patient",
+		})
+	}
+	if err := auth.CheckDevicePatient(db, device); err != nil {
+		if err == auth.ErrPatientDeactivated {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Patient account is deactivated",
+			})
+		}
+		log.Println("Error checking device patient:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Error authenticating device",
+		})
+	}
+
+	c.Locals("device", device)
+	return c.Next()
+}
+
+// CurrentDevice повертає автентифікований пристрій, збережений RequireDevice.
+func CurrentDevice(c *fiber.Ctx) *models.SmartGlassesDevice {
+	device, _ := c.Locals("device").(*models.SmartGlassesDevice)
+	return device
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Forbidden повертає єдину для всього API відповідь 403.
+func Forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"message": "Access denied",
+	})
+}
+
+// RequireRole пропускає запит лише користувачам з однією з вказаних ролей.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := CurrentUser(c)
+		if user == nil || !user.HasRole(roles...) {
+			return Forbidden(c)
+		}
+		return c.Next()
+	}
+}
+
+// RequireSelfOrRole пропускає запит, якщо ID у параметрі маршруту param
+// збігається з ID користувача або користувач має одну з вказаних ролей.
+func RequireSelfOrRole(param string, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := CurrentUser(c)
+		if user == nil {
+			return Forbidden(c)
+		}
+		if user.HasRole(roles...) || IsSelf(c, c.Params(param)) {
+			return c.Next()
+		}
+		return Forbidden(c)
+	}
+}
+
+// IsSelf перевіряє, чи є id (рядок з параметра або тіла запиту) ID автентифікованого користувача.
+func IsSelf(c *fiber.Ctx, id string) bool {
+	user := CurrentUser(c)
+	if user == nil {
+		return false
+	}
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	return err == nil && uint(parsed) == user.ID
+}
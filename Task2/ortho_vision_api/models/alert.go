@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Типи сповіщень
+const (
+	AlertTypePosture     = "posture"
+	AlertTypeLowLight    = "low_light"
+	AlertTypeHighLight   = "high_light"
+	AlertStatusOpen      = "open"      // Порушення триває
+	AlertStatusRecovered = "recovered" // Показники повернулися в норму
+	AlertStatusResolved  = "resolved"  // Закрите лікарем або пацієнтом вручну
+)
+
+// Модель для таблиці Alerts.
+// Сповіщення створюється, коли показник пацієнта виходить за межі довше за налаштований час,
+// і закривається автоматично, коли показник повертається в норму.
+type Alert struct {
+	ID               uint       `gorm:"primary_key" json:"id"`
+	PatientID        uint       `gorm:"not null;index" json:"patient_id"`
+	DeviceID         *uint      `json:"device_id"`
+	Type             string     `gorm:"not null;check:type in ('posture', 'low_light', 'high_light')" json:"type"`
+	Status           string     `gorm:"not null;index;check:status in ('open', 'recovered', 'resolved')" json:"status"`
+	Threshold        float64    `json:"threshold"`                  // Межа, що діяла під час порушення
+	PeakValue        float64    `json:"peak_value"`                 // Найгірше значення за час порушення
+	StartedAt        time.Time  `gorm:"not null" json:"started_at"` // Час першого показника з порушенням
+	OpenedAt         time.Time  `gorm:"not null" json:"opened_at"`  // Час, коли тривалість порушення перевищила допустиму
+	RecoveredAt      *time.Time `json:"recovered_at"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at"`
+	AcknowledgedByID *uint      `json:"acknowledged_by_id"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	ResolvedByID     *uint      `json:"resolved_by_id"`
+	ResolutionNote   string     `json:"resolution_note"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
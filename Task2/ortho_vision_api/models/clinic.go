@@ -9,6 +9,7 @@ type Clinic struct {
 	Address   string    `gorm:"not null"`
 	Phone     string    `gorm:"size:20"`
 	Location  string    `gorm:"not null"`
+	Timezone  string    `gorm:"size:64"` // Часовий пояс IANA для пацієнтів клініки; порожній — пояс за замовчуванням
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Модель для таблиці EmailVerificationTokens.
+// Зберігається лише хеш токена; токен одноразовий і має обмежений термін дії.
+// Токен підтверджує лише ту адресу, на яку його надіслано.
+type EmailVerificationToken struct {
+	ID        uint       `gorm:"primary_key"`
+	UserID    uint       `gorm:"not null;index"`
+	User      User       `gorm:"foreignkey:UserID;constraint:OnDelete:CASCADE"`
+	TokenHash string     `gorm:"not null;uniqueIndex"`
+	Email     string     `gorm:"not null;default:''"` // Адреса, на яку надіслано лист; токени без неї недійсні
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time `gorm:"default:null"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Візерунки руху світлодіодів у вправі для очей
+const (
+	ExercisePatternHorizontal = "horizontal" // Погляд ліворуч-праворуч
+	ExercisePatternVertical   = "vertical"   // Погляд угору-вниз
+	ExercisePatternCircle     = "circle"     // Колові рухи очима
+	ExercisePatternNearFar    = "near_far"   // Перефокусування з близької точки на далеку
+	ExercisePatternBlink      = "blink"      // Часте кліпання
+	ExercisePatternRelax      = "relax"      // Відпочинок із заплющеними очима
+)
+
+// Модель для таблиці ExercisePrograms.
+// Програма — послідовність кроків світлодіодного тренування очей, яку пацієнт виконує
+// SessionsPerDay разів на добу. Призначену програму не змінюють: зміна створює нову версію,
+// щоб історія виконання вправ і надалі рахувалася за тією програмою, що діяла тоді.
+type ExerciseProgram struct {
+	ID                uint           `gorm:"primary_key" json:"id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Description       string         `json:"description"`
+	SessionsPerDay    int            `gorm:"not null" json:"sessions_per_day"`
+	Steps             []ExerciseStep `gorm:"foreignkey:ProgramID;constraint:OnDelete:CASCADE" json:"steps"`
+	CreatedByID       uint           `json:"created_by_id"`                    // Лікар або адміністратор, який створив програму (або її версію)
+	PreviousVersionID *uint          `gorm:"index" json:"previous_version_id"` // Попередня версія програми
+	SupersededByID    *uint          `json:"superseded_by_id"`                 // Новіша версія; nil — програма актуальна
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+// Модель для таблиці ExerciseSteps — один крок програми.
+type ExerciseStep struct {
+	ID              uint   `gorm:"primary_key" json:"-"`
+	ProgramID       uint   `gorm:"not null;index" json:"-"`
+	Position        int    `gorm:"not null" json:"position"` // Порядковий номер кроку, починаючи з 1
+	Pattern         string `gorm:"not null" json:"pattern"`
+	Color           string `gorm:"size:7" json:"color"`              // Колір світлодіодів у форматі #RRGGBB
+	Brightness      int    `json:"brightness"`                       // Яскравість, %
+	DurationSeconds int    `gorm:"not null" json:"duration_seconds"` // Тривалість кроку
+}
+
+// Модель для таблиці ExerciseAssignments.
+// Призначення програми пацієнтові; у пацієнта може бути лише одне активне призначення.
+type ExerciseAssignment struct {
+	ID           uint             `gorm:"primary_key" json:"id"`
+	PatientID    uint             `gorm:"not null;uniqueIndex:idx_exercise_assignment_active,where:ended_at IS NULL" json:"patient_id"`
+	ProgramID    uint             `gorm:"not null;index" json:"program_id"`
+	Program      *ExerciseProgram `gorm:"foreignkey:ProgramID;constraint:OnDelete:RESTRICT" json:"program,omitempty"`
+	AssignedByID uint             `json:"assigned_by_id"`
+	Notes        string           `json:"notes"`
+	StartedAt    time.Time        `gorm:"not null" json:"started_at"`
+	EndedAt      *time.Time       `json:"ended_at"` // nil — призначення активне
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+// Модель для таблиці ExerciseCompletions.
+// Звіт пристрою про виконання сеансу тренування; повторно надісланий звіт не дублюється.
+type ExerciseCompletion struct {
+	ID             uint      `gorm:"primary_key" json:"id"`
+	AssignmentID   uint      `gorm:"not null;index" json:"assignment_id"`
+	PatientID      uint      `gorm:"not null;index:idx_exercise_completion_patient_time,priority:1" json:"patient_id"`
+	DeviceID       uint      `gorm:"not null;uniqueIndex:idx_exercise_completion_device_start,priority:1" json:"device_id"`
+	StartedAt      time.Time `gorm:"not null;uniqueIndex:idx_exercise_completion_device_start,priority:2;index:idx_exercise_completion_patient_time,priority:2" json:"started_at"`
+	FinishedAt     time.Time `gorm:"not null" json:"finished_at"`
+	StepsCompleted int       `json:"steps_completed"`
+	Completed      bool      `json:"completed"` // Виконано всі кроки програми
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Модель для таблиці ExternalIdentities.
+// Пов'язує обліковий запис у зовнішньому провайдері (Google, OIDC) з користувачем.
+type ExternalIdentity struct {
+	ID        uint   `gorm:"primary_key"`
+	UserID    uint   `gorm:"not null;index"`
+	User      User   `gorm:"foreignkey:UserID;constraint:OnDelete:CASCADE"`
+	Issuer    string `gorm:"not null;uniqueIndex:idx_external_identity"`
+	Subject   string `gorm:"not null;uniqueIndex:idx_external_identity"` // Ідентифікатор користувача в провайдера (claim "sub")
+	Email     string
+	CreatedAt time.Time
+}
+
+// Модель для таблиці OIDCLoginRequests.
+// Зберігає state, nonce і PKCE code verifier між перенаправленням до провайдера та поверненням.
+// Сам state також передається браузеру в cookie, тож завершити вхід може лише той браузер, що його почав.
+type OIDCLoginRequest struct {
+	ID           uint      `gorm:"primary_key"`
+	StateHash    string    `gorm:"not null;uniqueIndex"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Модель для таблиці LoginAttempts.
+// Зберігає лічильник невдалих спроб входу для облікового запису або IP-адреси,
+// щоб обмеження діяли спільно для кількох екземплярів сервера.
+type LoginAttempt struct {
+	AttemptKey    string     `gorm:"primaryKey"` // Наприклад "account:user@example.com" або "ip:10.0.0.1"
+	Failures      int        `gorm:"not null;default:0"`
+	LastFailureAt time.Time  `gorm:"not null"`
+	LockedUntil   *time.Time `gorm:"default:null"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Модель для таблиці PasswordResetTokens.
+// Зберігається лише хеш токена; токен одноразовий і має обмежений термін дії.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primary_key"`
+	UserID    uint       `gorm:"not null;index"`
+	User      User       `gorm:"foreignkey:UserID;constraint:OnDelete:CASCADE"`
+	TokenHash string     `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time `gorm:"default:null"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Модель для таблиці RefreshTokens.
+// Кожен рядок — один токен оновлення; при ротації старий токен відкликається,
+// а новий отримує той самий SessionID, тому сесія пристрою зберігається.
+type RefreshToken struct {
+	ID        uint       `gorm:"primary_key" json:"-"`
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	User      User       `gorm:"foreignkey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	SessionID string     `gorm:"not null;index" json:"session_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"` // SHA-256 від токена, сам токен не зберігається
+	Device    string     `json:"device"`                        // User-Agent клієнта
+	IP        string     `json:"ip"`
+	StartedAt time.Time  `gorm:"not null" json:"started_at"` // Час входу, з якого почалася сесія
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"-"`
+	CreatedAt time.Time  `json:"last_used_at"` // Час останньої ротації
+}
@@ -6,11 +6,18 @@ import (
 
 // Модель для таблиці SmartGlassesData
 type SmartGlassesData struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	UserID       uint      `json:"user_id"`
-	PostureAngle float64   `json:"posture_angle"`
-	EyeStrain    float64   `json:"eye_strain"`
-	Timestamp    time.Time `json:"timestamp"`
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"user_id" gorm:"index:idx_smartglassesdata_user_time,priority:1"`
+	DeviceID       *uint     `json:"device_id" gorm:"index;uniqueIndex:idx_smartglassesdata_device_boot_seq,priority:1"`                               // Пристрій, який надіслав показник
+	BootID         string    `json:"boot_id,omitempty" gorm:"size:64;not null;default:'';uniqueIndex:idx_smartglassesdata_device_boot_seq,priority:2"` // Ідентифікатор запуску прошивки, з якого почалася нумерація sequence
+	Sequence       *int64    `json:"sequence,omitempty" gorm:"uniqueIndex:idx_smartglassesdata_device_boot_seq,priority:3"`                            // Монотонний номер показника в межах boot_id; повтори з тим самим номером не зберігаються
+	SchemaVersion  int       `json:"schema_version" gorm:"default:1"`                                                                                  // Версія формату, у якому пристрій надіслав показник
+	PostureAngle   float64   `json:"posture_angle"`                                                                                                    // Нахил голови, °
+	AmbientLux     float64   `json:"ambient_lux"`                                                                                                      // Освітленість, lux (у версії 1 — поле eye_strain)
+	ScreenDistance *float64  `json:"screen_distance_cm,omitempty"`                                                                                     // Відстань до екрана, см
+	BlinkRate      *float64  `json:"blink_rate,omitempty"`                                                                                             // Кількість кліпань за хвилину
+	BatteryLevel   *float64  `json:"battery_level,omitempty"`                                                                                          // Заряд батареї, %
+	Timestamp      time.Time `json:"timestamp" gorm:"index:idx_smartglassesdata_user_time,priority:2"`
 }
 
 // Вказуємо правильну назву таблиці
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Модель для таблиці SmartGlassesDevices.
+// Кожен пристрій має власний API-ключ (зберігається лише хеш) і може бути прив'язаний до одного пацієнта.
+// Показники, надіслані пристроєм, зберігаються для пацієнта, до якого він прив'язаний.
+type SmartGlassesDevice struct {
+	ID                   uint       `gorm:"primary_key" json:"id"`
+	SerialNumber         string     `gorm:"not null;uniqueIndex" json:"serial_number"`
+	PatientID            *uint      `gorm:"index" json:"patient_id"` // nil — пристрій ще не прив'язаний
+	Patient              *User      `gorm:"foreignkey:PatientID;constraint:OnDelete:SET NULL" json:"-"`
+	APIKeyHash           string     `gorm:"not null;uniqueIndex" json:"-"` // SHA-256 від ключа, сам ключ не зберігається
+	PairingCodeHash      string     `gorm:"index" json:"-"`                // Одноразовий код прив'язки
+	PairingCodeExpiresAt *time.Time `json:"-"`
+	PairedAt             *time.Time `json:"paired_at"`
+	LastSeenAt           *time.Time `json:"last_seen_at"`
+	DisabledAt           *time.Time `json:"disabled_at"` // Вимкнений пристрій не може надсилати дані
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+// IsPaired повертає true, якщо пристрій прив'язаний до пацієнта.
+func (d *SmartGlassesDevice) IsPaired() bool {
+	return d.PatientID != nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// SmartGlassesRollup — агреговані показники одного пацієнта за інтервал (хвилину або годину).
+// Тривалість порушень (у секундах) рахується з межами, що діяли для пацієнта на момент агрегації;
+// після зміни меж агрегати пацієнта перебудовуються за весь період, за який ще є сирі показники.
+type SmartGlassesRollup struct {
+	UserID               uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	BucketStart          time.Time `gorm:"primaryKey;index" json:"bucket_start"`
+	Readings             int64     `gorm:"not null" json:"readings"`
+	PostureAngleSum      float64   `json:"posture_angle_sum"`
+	PostureAngleMin      float64   `json:"posture_angle_min"`
+	PostureAngleMax      float64   `json:"posture_angle_max"`
+	AmbientLuxSum        float64   `json:"ambient_lux_sum"`
+	AmbientLuxMin        float64   `json:"ambient_lux_min"`
+	AmbientLuxMax        float64   `json:"ambient_lux_max"`
+	TimeHeadTiltExceeded float64   `json:"time_head_tilt_exceeded"`
+	TimeLowLight         float64   `json:"time_low_light"`
+	TimeHighLight        float64   `json:"time_high_light"`
+}
+
+// Модель для таблиці smartglassesdata_minute — похвилинні агрегати
+type SmartGlassesMinuteRollup struct {
+	SmartGlassesRollup
+}
+
+// Вказуємо назву таблиці похвилинних агрегатів
+func (SmartGlassesMinuteRollup) TableName() string {
+	return "smartglassesdata_minute"
+}
+
+// Модель для таблиці smartglassesdata_hour — погодинні агрегати
+type SmartGlassesHourRollup struct {
+	SmartGlassesRollup
+}
+
+// Вказуємо назву таблиці погодинних агрегатів
+func (SmartGlassesHourRollup) TableName() string {
+	return "smartglassesdata_hour"
+}
+
+// Модель для таблиці smartglassesdata_rollup_pending.
+// Для кожного пацієнта зберігає час найстарішого показника, який ще не враховано в агрегатах
+// (нові показники або показники, надіслані із запізненням після роботи офлайн).
+type SmartGlassesRollupPending struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false"`
+	PendingFrom time.Time `gorm:"not null"`
+}
+
+// Вказуємо назву таблиці
+func (SmartGlassesRollupPending) TableName() string {
+	return "smartglassesdata_rollup_pending"
+}
+
+// Модель для таблиці smartglassesdata_rollup_state.
+// RolledUpTo — час, до якого агрегати побудовано для всіх пацієнтів без незавершених показників.
+type SmartGlassesRollupState struct {
+	Name       string    `gorm:"primaryKey"`
+	RolledUpTo time.Time `gorm:"not null"`
+}
+
+// Вказуємо назву таблиці
+func (SmartGlassesRollupState) TableName() string {
+	return "smartglassesdata_rollup_state"
+}
+
+// Модель для таблиці smartglassesdata_rollup_watermark.
+// Для кожного пацієнта зберігає час, до якого сирі показники вже враховано в агрегатах
+// і більше не потрібні для їх перебудови. Сирі показники пацієнта без такого запису не видаляються.
+type SmartGlassesRollupWatermark struct {
+	UserID     uint      `gorm:"primaryKey;autoIncrement:false"`
+	RolledUpTo time.Time `gorm:"not null"`
+}
+
+// Вказуємо назву таблиці
+func (SmartGlassesRollupWatermark) TableName() string {
+	return "smartglassesdata_rollup_watermark"
+}
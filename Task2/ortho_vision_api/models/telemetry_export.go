@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Стани завдання експорту
+const (
+	ExportStatusPending   = "pending"   // Чекає на виконання
+	ExportStatusRunning   = "running"   // Файл створюється
+	ExportStatusCompleted = "completed" // Файл готовий до завантаження
+	ExportStatusFailed    = "failed"
+	ExportStatusExpired   = "expired" // Файл видалено після закінчення терміну зберігання
+)
+
+// Модель для таблиці TelemetryExports.
+// Фонове завдання експорту показників когорти пацієнтів у файл CSV або Parquet.
+// Когорта задається переліком пацієнтів, клінікою та діагнозом; заданий фільтр звужує вибірку,
+// порожній — не обмежує її.
+type TelemetryExport struct {
+	ID            uint       `gorm:"primary_key" json:"id"`
+	RequestedByID uint       `gorm:"not null;index" json:"requested_by_id"`
+	Format        string     `gorm:"size:16;not null;check:format in ('csv', 'parquet')" json:"format"`
+	Level         string     `gorm:"size:16;not null;check:level in ('raw', 'minute', 'hour')" json:"level"` // Сирі показники або похвилинні чи погодинні агрегати
+	PatientIDs    []uint     `gorm:"type:text;serializer:json" json:"patient_ids"`
+	ClinicID      *uint      `json:"clinic_id"`
+	Disease       string     `json:"disease"` // Назва діагнозу (без урахування регістру)
+	From          time.Time  `gorm:"not null" json:"from"`
+	To            time.Time  `gorm:"not null" json:"to"`
+	Status        string     `gorm:"size:16;not null;index;check:status in ('pending', 'running', 'completed', 'failed', 'expired')" json:"status"`
+	Rows          int64      `json:"rows"`
+	SizeBytes     int64      `json:"size_bytes"`
+	FilePath      string     `json:"-"`
+	Error         string     `json:"error,omitempty"`
+	PseudonymKey  string     `gorm:"size:64;not null;default:''" json:"-"` // Ключ псевдонімів пацієнтів і пристроїв у файлі
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	HeartbeatAt   *time.Time `json:"-"` // Оновлюється, поки завдання виконується; без оновлень завдання вважається перерваним
+	CompletedAt   *time.Time `json:"completed_at"`
+	ExpiresAt     *time.Time `gorm:"index" json:"expires_at"` // Після цього часу файл видаляється
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Модель для таблиці ThresholdProfiles.
+// Профіль задає межі для аналізу постави й освітлення: або за замовчуванням для клініки (ClinicID),
+// або індивідуально для пацієнта (PatientID). Порожнє поле означає, що значення успадковується:
+// пацієнт → клініка пацієнта → загальні налаштування сервера.
+type ThresholdProfile struct {
+	ID              uint      `gorm:"primary_key" json:"id"`
+	ClinicID        *uint     `gorm:"uniqueIndex" json:"clinic_id,omitempty"`
+	PatientID       *uint     `gorm:"uniqueIndex" json:"patient_id,omitempty"`
+	MaxPostureAngle *float64  `json:"max_posture_angle"` // Максимальний нахил голови, °
+	MinLux          *float64  `json:"min_lux"`           // Мінімальна освітленість, lux
+	MaxLux          *float64  `json:"max_lux"`           // Максимальна освітленість, lux
+	UpdatedByID     uint      `json:"updated_by_id"`     // Лікар або адміністратор, який востаннє змінив профіль
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Модель для таблиці TOTPRecoveryCodes.
+// Одноразові коди відновлення на випадок втрати пристрою з TOTP; зберігаються лише хеші.
+type TOTPRecoveryCode struct {
+	ID        uint       `gorm:"primary_key"`
+	UserID    uint       `gorm:"not null;index"`
+	User      User       `gorm:"foreignkey:UserID;constraint:OnDelete:CASCADE"`
+	CodeHash  string     `gorm:"not null;index"`
+	UsedAt    *time.Time `gorm:"default:null"`
+	CreatedAt time.Time
+}
+
+// Модель для таблиці TwoFactorPolicies.
+// Визначає, для яких ролей двофакторна автентифікація обов'язкова.
+type TwoFactorPolicy struct {
+	Role      string    `gorm:"primaryKey;check:role in ('patient', 'admin', 'doctor')" json:"role"`
+	Required  bool      `gorm:"not null;default:false" json:"required"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
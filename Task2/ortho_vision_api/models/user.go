@@ -4,13 +4,42 @@ import (
 	"time"
 )
 
+// Ролі користувачів
+const (
+	RolePatient = "patient"
+	RoleAdmin   = "admin"
+	RoleDoctor  = "doctor"
+)
+
 // Модель для таблиці Users
 type User struct {
-	ID           uint   `gorm:"primary_key"`
-	Name         string `gorm:"not null"`
-	Email        string `gorm:"unique;not null"`
-	PasswordHash string `gorm:"not null"`
-	Role         string `gorm:"not null;check:role in ('patient', 'admin', 'doctor')"`
-	CreatedAt    time.Time
-	Password     string `gorm:"-"`
+	ID            uint       `gorm:"primary_key"`
+	Name          string     `gorm:"not null"`
+	Email         string     `gorm:"unique;not null"`
+	PasswordHash  string     `gorm:"not null" json:"-"`
+	Role          string     `gorm:"not null;check:role in ('patient', 'admin', 'doctor')"`
+	EmailVerified bool       `gorm:"not null;default:false"`      // Чи підтвердив користувач свою електронну адресу
+	TOTPSecret    string     `gorm:"default:null" json:"-"`       // Секрет TOTP (зберігається й під час незавершеного підключення)
+	TOTPEnabled   bool       `gorm:"not null;default:false"`      // Чи увімкнено двофакторну автентифікацію
+	TOTPLastStep  int64      `gorm:"not null;default:0" json:"-"` // Крок часу останнього прийнятого коду TOTP (повторно код не приймається)
+	DeactivatedAt *time.Time `gorm:"default:null"`                // Час деактивації; деактивований користувач не може увійти
+	ClinicID      *uint      `gorm:"index"`                       // Клініка, що спостерігає пацієнта (її налаштування застосовуються за замовчуванням)
+	Timezone      string     `gorm:"size:64"`                     // Часовий пояс IANA (наприклад, Europe/Kyiv); порожній — пояс клініки
+	CreatedAt     time.Time
+	Password      string `gorm:"-"`
+}
+
+// IsActive перевіряє, чи не деактивовано обліковий запис.
+func (u *User) IsActive() bool {
+	return u.DeactivatedAt == nil
+}
+
+// HasRole перевіряє, чи має користувач одну з вказаних ролей.
+func (u *User) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
 }
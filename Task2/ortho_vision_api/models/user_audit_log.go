@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Дії адміністратора, що записуються в журнал
+const (
+	AuditActionRoleChanged = "role_changed"
+	AuditActionDeactivated = "deactivated"
+	AuditActionReactivated = "reactivated"
+)
+
+// Модель для таблиці UserAuditLogs.
+// Журнал змін облікових записів, зроблених адміністраторами.
+type UserAuditLog struct {
+	ID           uint      `gorm:"primary_key" json:"id"`
+	ActorID      uint      `gorm:"not null;index" json:"actor_id"`       // Адміністратор, який виконав дію
+	TargetUserID uint      `gorm:"not null;index" json:"target_user_id"` // Користувач, якого змінено
+	Action       string    `gorm:"not null" json:"action"`
+	OldValue     string    `json:"old_value"`
+	NewValue     string    `json:"new_value"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Модель для таблиці WeeklyReports.
+// Щотижневий звіт про поставу й освітлення пацієнта з порівнянням із базовою лінією
+// попередніх тижнів. Частки — це частина часу носіння окулярів, коли показник був поза межами.
+type WeeklyReport struct {
+	ID                   uint      `gorm:"primary_key" json:"id"`
+	PatientID            uint      `gorm:"not null;uniqueIndex:idx_weekly_report_patient_week,priority:1" json:"patient_id"`
+	WeekStart            time.Time `gorm:"not null;uniqueIndex:idx_weekly_report_patient_week,priority:2" json:"week_start"` // Понеділок 00:00 за місцевим часом пацієнта
+	WeekEnd              time.Time `gorm:"not null" json:"week_end"`
+	Timezone             string    `gorm:"size:64" json:"timezone"`
+	DaysWithData         int       `json:"days_with_data"`
+	WornSeconds          float64   `json:"worn_seconds"`
+	AvgPostureAngle      *float64  `json:"avg_posture_angle"`
+	AvgAmbientLux        *float64  `json:"avg_ambient_lux"`
+	TiltShare            *float64  `json:"tilt_share"`  // Частка часу з надмірним нахилом голови
+	LightShare           *float64  `json:"light_share"` // Частка часу з освітленням поза межами
+	BaselineDays         int       `json:"baseline_days"`
+	BaselineTiltShare    *float64  `json:"baseline_tilt_share"`
+	BaselineLightShare   *float64  `json:"baseline_light_share"`
+	TiltZScore           *float64  `json:"tilt_z_score"` // nil — недостатньо даних або нульовий розкид
+	LightZScore          *float64  `json:"light_z_score"`
+	PostureDeteriorated  bool      `gorm:"index" json:"posture_deteriorated"` // Статистично значуще погіршення постави
+	LightingDeteriorated bool      `gorm:"index" json:"lighting_deteriorated"`
+	CreatedAt            time.Time `json:"created_at"`
+}
@@ -2,6 +2,8 @@ package routes
 
 import (
 	"ortho_vision_api/controllers"
+	"ortho_vision_api/middleware"
+	"ortho_vision_api/models"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,46 +15,204 @@ func SetupRoutes(app *fiber.App) {
 
 	app.Get("/login", controllers.LoginUser) // Вхід користувача
 
-	app.Put("/users/:id", controllers.UpdateUser) // Оновлення даних користувача
+	app.Post("/token/refresh", controllers.RefreshTokens) // Оновлення токенів за токеном оновлення
 
-	app.Delete("/admin/users/:id", controllers.DeleteUser) // Видалення користувача за ID
+	app.Post("/password/forgot", controllers.ForgotPassword) // Надсилання листа для скидання пароля
 
-	app.Post("/admin/clinics", controllers.AddClinic) // Додавання клініки
+	app.Post("/password/reset", controllers.ResetPassword) // Встановлення нового пароля за токеном
 
-	app.Get("/admin/clinics", controllers.GetAllClinics) // Отримання всіх клінік
+	app.Get("/verify-email", controllers.VerifyEmail) // Підтвердження email за посиланням із листа
 
-	app.Get("/admin/clinics/:name", controllers.GetClinicByName) // Отримання клініки за назвою
+	app.Get("/auth/oidc/login", controllers.OIDCLogin) // Вхід через Google / OpenID Connect
 
-	app.Delete("/admin/clinics/:id", controllers.DeleteClinic) // Видалення клініки за ID
+	app.Get("/auth/oidc/callback", controllers.OIDCCallback) // Повернення від провайдера OpenID Connect
 
-	app.Post("/doctor/:doctor_id/appointment_times", controllers.CreateAppointmentTime) // Створення вільного часу доктора
+	app.Post("/login/2fa", controllers.LoginTwoFactor) // Другий крок входу: код TOTP або код відновлення
 
-	app.Put("/doctor/:doctor_id/appointment_times/:appointment_time_id", controllers.UpdateAppointmentTime) // Редагування вільного часу доктора
+	// Смарт-окуляри надсилають показники з власним API-ключем, а не токеном користувача
+	app.Post("/smart-glasses", middleware.RequireDevice, controllers.AddSmartGlassesData)
 
-	app.Get("/doctor/:doctor_id/appointment_times", controllers.CreateAppointmentTime) // Отримання всіх вільних місць доктора
+	app.Post("/smart-glasses/batch", middleware.RequireDevice, controllers.AddSmartGlassesDataBatch) // Пакет показників (JSON-масив або NDJSON) з часом пристрою
 
-	app.Delete("/doctor/:doctor_id/appointment_times/:appointment_time_id", controllers.DeleteAppointmentTime) // Видалення конкретного вільного часу
+	app.Get("/smart-glasses/sequence-gaps", middleware.RequireDevice, controllers.GetSequenceGaps) // Пропущені номери послідовності, які пристрій має надіслати повторно
 
-	app.Get("/appointment-times/search", controllers.SearchAppointmentTimes) // Знайти вільні години до лікаря за часом або лікарем
+	app.Get("/smart-glasses/break-status", middleware.RequireDevice, controllers.GetBreakStatus) // Чи настав час перерви за правилом 20-20-20
 
-	app.Post("/appointments", controllers.CreateAppointment) //Запис на прийом
+	app.Get("/smart-glasses/exercise-program", middleware.RequireDevice, controllers.GetDeviceExerciseProgram) // Активна програма вправ для очей (204 — не призначена)
 
-	app.Delete("/appointments/:id", controllers.DeleteAppointment) // Видалення запису на прийом
+	app.Post("/smart-glasses/exercise-completions", middleware.RequireDevice, controllers.ReportExerciseCompletion) // Звіт про виконаний сеанс вправ
 
-	app.Get("/appointments/patient/:patientID", controllers.GetAppointmentsByPatientID) // Отримати історію всі прийомів
+	// Усі наступні маршрути доступні лише з дійсним токеном доступу
+	protected := app.Group("", middleware.RequireAuth)
 
-	app.Post("/diseases", controllers.CreateDisease) // Створення нового запису про хворобу
+	protected.Post("/logout", controllers.Logout) // Вихід з поточної сесії
 
-	app.Delete("/diseases/:id", controllers.DeleteDisease) // Видалення запису про хворобу
+	protected.Post("/logout/all", controllers.LogoutAll) // Вихід з усіх пристроїв
 
-	app.Put("/diseases/:id", controllers.UpdateDisease) // Оновлення запису про хворобу
+	protected.Get("/sessions", controllers.GetSessions) // Активні сесії користувача
 
-	app.Get("/medical-record/:patientID", controllers.GetMedicalRecord) // Отримання всіх хвороб пацієнта за його ID
+	protected.Delete("/sessions/:id", controllers.RevokeSession) // Відкликання сесії
 
-	app.Get("/clinic-stats", controllers.GetClinicDiseaseStats)
+	protected.Post("/verify-email/resend", controllers.ResendVerificationEmail) // Повторне надсилання листа з підтвердженням
+
+	protected.Post("/2fa/setup", controllers.SetupTwoFactor) // Створення секрету TOTP і URI для QR-коду
+
+	protected.Post("/2fa/enable", controllers.EnableTwoFactor) // Підтвердження підключення TOTP
+
+	protected.Post("/2fa/disable", controllers.DisableTwoFactor) // Вимкнення TOTP
+
+	protected.Post("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes) // Нові коди відновлення
+
+	// Наступні маршрути недоступні, доки користувач не підключить обов'язкову для його ролі 2FA
+	secured := protected.Group("", middleware.RequireTwoFactorEnrollment)
+
+	secured.Put("/users/:id", middleware.RequireSelfOrRole("id", models.RoleAdmin), controllers.UpdateUser) // Оновлення даних користувача
+
+	// Маршрути адміністратора
+	admin := secured.Group("/admin", middleware.RequireRole(models.RoleAdmin))
+
+	admin.Get("/users", controllers.ListUsers) // Список користувачів з фільтрами за роллю, статусом і пошуком
+
+	admin.Delete("/users/:id", controllers.DeleteUser) // Видалення користувача за ID (лише без медичної історії)
+
+	admin.Put("/users/:id/role", controllers.ChangeUserRole) // Зміна ролі користувача
+
+	admin.Post("/users/:id/deactivate", controllers.DeactivateUser) // Деактивація облікового запису
+
+	admin.Post("/users/:id/reactivate", controllers.ReactivateUser) // Повторна активація облікового запису
+
+	admin.Get("/users/:id/audit", controllers.GetUserAuditLog) // Журнал змін облікового запису
+
+	admin.Post("/users/:id/unlock", controllers.UnlockUser) // Зняття блокування входу після невдалих спроб
+
+	admin.Get("/2fa-policy", controllers.GetTwoFactorPolicies) // Ролі, для яких 2FA обов'язкова
+
+	admin.Put("/2fa-policy", controllers.UpdateTwoFactorPolicy) // Зміна обов'язковості 2FA для ролі
+
+	admin.Post("/devices", controllers.RegisterDevice) // Реєстрація смарт-окулярів (повертає API-ключ і код прив'язки)
+
+	admin.Get("/devices", controllers.GetAllDevices) // Усі пристрої, за потреби — одного пацієнта
+
+	admin.Post("/devices/:id/pairing-code", controllers.IssuePairingCode) // Новий код прив'язки
+
+	admin.Post("/devices/:id/api-key", controllers.RotateDeviceKey) // Заміна API-ключа пристрою
+
+	admin.Post("/devices/:id/disable", controllers.DisableDevice) // Вимкнення втраченого пристрою
+
+	admin.Get("/clinics/:id/thresholds", controllers.GetClinicThresholds) // Порогові значення клініки за замовчуванням
+
+	admin.Put("/clinics/:id/thresholds", controllers.UpdateClinicThresholds) // Встановлення порогових значень клініки
+
+	admin.Delete("/clinics/:id/thresholds", controllers.DeleteClinicThresholds) // Скидання порогових значень клініки до загальних
+
+	admin.Post("/clinics", controllers.AddClinic) // Додавання клініки
+
+	admin.Put("/clinics/:id/timezone", controllers.UpdateClinicTimezone) // Часовий пояс клініки для добової статистики пацієнтів
+
+	admin.Get("/clinics", controllers.GetAllClinics) // Отримання всіх клінік
+
+	admin.Get("/clinics/:name", controllers.GetClinicByName) // Отримання клініки за назвою
+
+	admin.Delete("/clinics/:id", controllers.DeleteClinic) // Видалення клініки за ID
+
+	// Маршрути лікаря — доступні самому лікарю або адміністратору
+	doctor := secured.Group("/doctor/:doctor_id",
+		middleware.RequireRole(models.RoleDoctor, models.RoleAdmin),
+		middleware.RequireSelfOrRole("doctor_id", models.RoleAdmin),
+	)
+
+	doctor.Post("/appointment_times", controllers.CreateAppointmentTime) // Створення вільного часу доктора
+
+	doctor.Put("/appointment_times/:appointment_time_id", controllers.UpdateAppointmentTime) // Редагування вільного часу доктора
+
+	doctor.Get("/appointment_times", controllers.GetAllAppointmentTimesForDoctor) // Отримання всіх вільних місць доктора
+
+	doctor.Delete("/appointment_times/:appointment_time_id", controllers.DeleteAppointmentTime) // Видалення конкретного вільного часу
+
+	secured.Get("/appointment-times/search", controllers.SearchAppointmentTimes) // Знайти вільні години до лікаря за часом або лікарем
+
+	secured.Post("/appointments", controllers.CreateAppointment) //Запис на прийом
+
+	secured.Delete("/appointments/:id", controllers.DeleteAppointment) // Видалення запису на прийом
+
+	// Дані пацієнта — пацієнт бачить лише свої дані, лікар і адміністратор — будь-які
+	secured.Get("/appointments/patient/:patientID", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.GetAppointmentsByPatientID) // Отримати історію всі прийомів
+
+	secured.Get("/medical-record/:patientID", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.GetMedicalRecord) // Отримання всіх хвороб пацієнта за його ID
+
+	secured.Get("/patients/:patientID/thresholds", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.GetPatientThresholds) // Порогові значення, що діють для пацієнта
+
+	secured.Put("/patients/:patientID/thresholds", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin), controllers.UpdatePatientThresholds) // Індивідуальні порогові значення пацієнта
+
+	secured.Delete("/patients/:patientID/thresholds", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin), controllers.DeletePatientThresholds) // Повернення до порогових значень клініки
+
+	secured.Get("/patients/:patientID/screen-time", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.GetScreenTimeReport) // Сеанси роботи на близькій відстані та дотримання перерв за добу або тиждень
+
+	secured.Get("/patients/:patientID/exercise-program", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.GetPatientExerciseProgram) // Активна програма вправ пацієнта
+
+	secured.Post("/patients/:patientID/exercise-program", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin), controllers.AssignExerciseProgram) // Призначення програми вправ пацієнтові
+
+	secured.Delete("/patients/:patientID/exercise-program", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin), controllers.EndExerciseProgram) // Завершення призначення вправ
+
+	secured.Get("/patients/:patientID/exercise-adherence", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.GetExerciseAdherence) // Виконання призначених вправ по добах
+
+	// Програми світлодіодних вправ для очей створюють лікар або адміністратор
+	exercises := secured.Group("/exercise-programs", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin))
+
+	exercises.Post("/", controllers.CreateExerciseProgram) // Створення програми вправ
+
+	exercises.Get("/", controllers.GetExercisePrograms) // Усі програми вправ
+
+	exercises.Get("/:id", controllers.GetExerciseProgram) // Програма вправ за ID
+
+	exercises.Put("/:id", controllers.UpdateExerciseProgram) // Зміна програми вправ
+
+	exercises.Delete("/:id", controllers.DeleteExerciseProgram) // Видалення непризначеної програми вправ
+
+	secured.Get("/alerts", controllers.GetAlerts) // Сповіщення про тривалі порушення постави й освітлення
+
+	secured.Post("/alerts/:id/acknowledge", controllers.AcknowledgeAlert) // Позначити сповіщення переглянутим
+
+	secured.Post("/alerts/:id/resolve", controllers.ResolveAlert) // Закрити сповіщення вручну
+
+	secured.Get("/weekly-reports", controllers.GetWeeklyReports) // Щотижневі звіти про тенденції (patient_id, deteriorated — для лікаря й адміністратора)
+
+	secured.Get("/weekly-reports/:id", controllers.GetWeeklyReport) // Щотижневий звіт за ID
+
+	// Експорт показників для досліджень — для лікаря й адміністратора
+	exports := secured.Group("/exports", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin))
+
+	exports.Get("/telemetry", controllers.ExportTelemetry) // Потоковий експорт у CSV або Parquet за короткий період
+
+	exports.Post("/", controllers.CreateTelemetryExport) // Фонове завдання експорту у файл
+
+	exports.Get("/", controllers.GetTelemetryExports) // Завдання експорту (адміністратор бачить усі)
+
+	exports.Get("/:id", controllers.GetTelemetryExport) // Стан завдання експорту
+
+	exports.Get("/:id/download", controllers.DownloadTelemetryExport) // Завантаження готового файлу
+
+	exports.Delete("/:id", controllers.DeleteTelemetryExport) // Скасування завдання або видалення файлу
+
+	// Медичні записи змінюють лише лікар або адміністратор
+	medical := secured.Group("/diseases", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin))
+
+	medical.Post("/", controllers.CreateDisease) // Створення нового запису про хворобу
+
+	medical.Delete("/:id", controllers.DeleteDisease) // Видалення запису про хворобу
+
+	medical.Put("/:id", controllers.UpdateDisease) // Оновлення запису про хворобу
+
+	secured.Get("/clinic-stats", middleware.RequireRole(models.RoleDoctor, models.RoleAdmin), controllers.GetClinicDiseaseStats)
 
 	// Запити для смарт-окулярів
-	app.Post("/smart-glasses", controllers.AddSmartGlassesData)
+	secured.Post("/smart-glasses/devices/pair", controllers.PairDevice) // Прив'язка пристрою до пацієнта кодом прив'язки
+
+	secured.Get("/smart-glasses/devices", controllers.GetMyDevices) // Пристрої поточного пацієнта
+
+	secured.Delete("/smart-glasses/devices/:id", controllers.UnpairDevice) // Відв'язка пристрою
+
+	secured.Get("/smart-glasses/live/:patientID", middleware.RequireSelfOrRole("patientID", models.RoleDoctor, models.RoleAdmin), controllers.StreamSmartGlassesData) // Показники пацієнта в реальному часі (SSE)
 
-	app.Get("/smart-glasses/statistics", controllers.GetSmartGlassesStatistics)
+	secured.Get("/smart-glasses/statistics", controllers.GetSmartGlassesStatistics) // Статистика за день (patient_id — для лікаря й адміністратора)
 }
@@ -0,0 +1,279 @@
+package telemetry
+
+import (
+	"log"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// alertRule описує одну умову, за якою відкривається сповіщення.
+type alertRule struct {
+	Type      string
+	Duration  func() time.Duration
+	Violated  func(Thresholds, models.SmartGlassesData) bool
+	Value     func(models.SmartGlassesData) float64
+	Threshold func(Thresholds) float64
+	Worse     func(value, peak float64) bool // Чи є нове значення гіршим за поточне найгірше
+}
+
+// alertRules — умови, які перевіряються для кожного показника.
+var alertRules = []alertRule{
+	{
+		Type:      models.AlertTypePosture,
+		Duration:  func() time.Duration { return config.AlertPostureDuration },
+		Violated:  func(t Thresholds, r models.SmartGlassesData) bool { return t.PostureExceeded(r.PostureAngle) },
+		Value:     func(r models.SmartGlassesData) float64 { return r.PostureAngle },
+		Threshold: func(t Thresholds) float64 { return t.MaxPostureAngle },
+		Worse:     func(value, peak float64) bool { return value > peak },
+	},
+	{
+		Type:      models.AlertTypeLowLight,
+		Duration:  func() time.Duration { return config.AlertLowLightDuration },
+		Violated:  func(t Thresholds, r models.SmartGlassesData) bool { return t.LowLight(r.AmbientLux) },
+		Value:     func(r models.SmartGlassesData) float64 { return r.AmbientLux },
+		Threshold: func(t Thresholds) float64 { return t.MinLux },
+		Worse:     func(value, peak float64) bool { return value < peak },
+	},
+	{
+		Type:      models.AlertTypeHighLight,
+		Duration:  func() time.Duration { return config.AlertHighLightDuration },
+		Violated:  func(t Thresholds, r models.SmartGlassesData) bool { return t.HighLight(r.AmbientLux) },
+		Value:     func(r models.SmartGlassesData) float64 { return r.AmbientLux },
+		Threshold: func(t Thresholds) float64 { return t.MaxLux },
+		Worse:     func(value, peak float64) bool { return value > peak },
+	},
+}
+
+// alertKey — пацієнт і тип умови.
+type alertKey struct {
+	PatientID uint
+	Type      string
+}
+
+// violation — поточне порушення однієї умови.
+type violation struct {
+	Since   time.Time // Перший показник із порушенням
+	Last    time.Time // Останній показник із порушенням
+	Peak    float64
+	AlertID uint // ID відкритого сповіщення, 0 — сповіщення ще не відкрито
+}
+
+// AlertEngine перевіряє збережені показники як потік і відкриває або закриває сповіщення.
+// Показники обробляються в одній горутині в порядку надходження, тому стан порушень
+// не потребує блокувань. Стан початих порушень зберігається в пам'яті; відкриті сповіщення
+// завантажуються з бази під час запуску і закриваються, щойно показники повернуться в норму.
+type AlertEngine struct {
+	db      *gorm.DB
+	queue   chan []models.SmartGlassesData
+	done    sync.WaitGroup
+	dropped atomic.Uint64 // Кількість показників, не перевірених через переповнену чергу
+
+	violations map[alertKey]*violation
+	lastSeen   map[uint]time.Time // Час останнього обробленого показника пацієнта
+}
+
+// NewAlertEngine створює AlertEngine. Щоб почати обробку, потрібно викликати Start.
+func NewAlertEngine(db *gorm.DB) *AlertEngine {
+	return &AlertEngine{
+		db:         db,
+		queue:      make(chan []models.SmartGlassesData, config.AlertQueueSize),
+		violations: make(map[alertKey]*violation),
+		lastSeen:   make(map[uint]time.Time),
+	}
+}
+
+// Start завантажує відкриті сповіщення і запускає обробку черги.
+func (e *AlertEngine) Start() error {
+	var open []models.Alert
+	if err := e.db.Where("status = ?", models.AlertStatusOpen).Find(&open).Error; err != nil {
+		return err
+	}
+	for _, alert := range open {
+		e.violations[alertKey{alert.PatientID, alert.Type}] = &violation{
+			Since:   alert.StartedAt,
+			Last:    alert.OpenedAt,
+			Peak:    alert.PeakValue,
+			AlertID: alert.ID,
+		}
+	}
+
+	e.done.Add(1)
+	go e.run()
+	return nil
+}
+
+// Stop завершує обробку після того, як черга спорожніє.
+func (e *AlertEngine) Stop() {
+	close(e.queue)
+	e.done.Wait()
+}
+
+// Enqueue ставить показники в чергу на перевірку. Підходить як обробник Hub.AddListener.
+// Виклик не чекає: якщо черга заповнена (наприклад, база даних відповідає повільно),
+// показники не перевіряються, щоб не гальмувати їх отримання від пристроїв.
+func (e *AlertEngine) Enqueue(readings []models.SmartGlassesData) {
+	batch := make([]models.SmartGlassesData, len(readings))
+	copy(batch, readings)
+	select {
+	case e.queue <- batch:
+	default:
+		total := e.dropped.Add(uint64(len(batch)))
+		log.Println("Alert engine: queue is full, readings skipped so far:", total)
+	}
+}
+
+// run обробляє чергу до виклику Stop.
+func (e *AlertEngine) run() {
+	defer e.done.Done()
+	for batch := range e.queue {
+		e.process(batch)
+	}
+}
+
+// process перевіряє пакет показників, згрупувавши їх за пацієнтами і впорядкувавши за часом.
+func (e *AlertEngine) process(batch []models.SmartGlassesData) {
+	byPatient := make(map[uint][]models.SmartGlassesData)
+	for _, reading := range batch {
+		byPatient[reading.UserID] = append(byPatient[reading.UserID], reading)
+	}
+
+	for patientID, readings := range byPatient {
+		thresholds, err := ResolveThresholds(e.db, patientID)
+		if err != nil {
+			log.Println("Alert engine: error resolving thresholds:", err)
+			continue
+		}
+
+		if err := e.forgetClosedAlerts(patientID); err != nil {
+			log.Println("Alert engine: error checking alert status:", err)
+		}
+
+		sort.Slice(readings, func(i, j int) bool {
+			return readings[i].Timestamp.Before(readings[j].Timestamp)
+		})
+		for _, reading := range readings {
+			// Заглушки старої прошивки не враховуються, як і в статистиці та агрегатах
+			if isLegacyPlaceholder(reading) {
+				continue
+			}
+			// Показники, старіші за вже оброблені (наприклад, надіслані після офлайну із запізненням),
+			// не впливають на поточний стан порушень
+			if !reading.Timestamp.After(e.lastSeen[patientID]) {
+				continue
+			}
+			e.lastSeen[patientID] = reading.Timestamp
+
+			for _, rule := range alertRules {
+				e.evaluate(rule, thresholds, reading)
+			}
+		}
+	}
+}
+
+// evaluate застосовує одну умову до показника.
+func (e *AlertEngine) evaluate(rule alertRule, thresholds Thresholds, reading models.SmartGlassesData) {
+	key := alertKey{reading.UserID, rule.Type}
+	current := e.violations[key]
+
+	// Довга перерва в показниках (окуляри зняли) перериває порушення, для якого ще немає сповіщення
+	if current != nil && current.AlertID == 0 && reading.Timestamp.Sub(current.Last) > config.AlertMaxGap {
+		delete(e.violations, key)
+		current = nil
+	}
+
+	if !rule.Violated(thresholds, reading) {
+		if current != nil {
+			if current.AlertID != 0 {
+				e.closeRecovered(current.AlertID, reading.Timestamp)
+			}
+			delete(e.violations, key)
+		}
+		return
+	}
+
+	value := rule.Value(reading)
+	if current == nil {
+		current = &violation{Since: reading.Timestamp, Peak: value}
+		e.violations[key] = current
+	}
+	current.Last = reading.Timestamp
+	worse := rule.Worse(value, current.Peak)
+	if worse {
+		current.Peak = value
+	}
+
+	switch {
+	case current.AlertID == 0 && reading.Timestamp.Sub(current.Since) >= rule.Duration():
+		alert := models.Alert{
+			PatientID: reading.UserID,
+			DeviceID:  reading.DeviceID,
+			Type:      rule.Type,
+			Status:    models.AlertStatusOpen,
+			Threshold: rule.Threshold(thresholds),
+			PeakValue: current.Peak,
+			StartedAt: current.Since,
+			OpenedAt:  reading.Timestamp,
+		}
+		if err := e.db.Create(&alert).Error; err != nil {
+			log.Println("Alert engine: error opening alert:", err)
+			return
+		}
+		current.AlertID = alert.ID
+	case current.AlertID != 0 && worse:
+		result := e.db.Model(&models.Alert{}).
+			Where("id = ? AND status = ?", current.AlertID, models.AlertStatusOpen).
+			Update("peak_value", current.Peak)
+		if result.Error != nil {
+			log.Println("Alert engine: error updating alert:", result.Error)
+			return
+		}
+		if result.RowsAffected == 0 {
+			// Сповіщення закрили вручну — порушення відраховується заново з цього показника
+			e.violations[key] = &violation{Since: reading.Timestamp, Last: reading.Timestamp, Peak: value}
+		}
+	}
+}
+
+// forgetClosedAlerts забуває порушення пацієнта, сповіщення про які вже закрито вручну:
+// якщо порушення триває, про нього буде відкрито нове сповіщення.
+func (e *AlertEngine) forgetClosedAlerts(patientID uint) error {
+	var alertIDs []uint
+	for key, current := range e.violations {
+		if key.PatientID == patientID && current.AlertID != 0 {
+			alertIDs = append(alertIDs, current.AlertID)
+		}
+	}
+	if len(alertIDs) == 0 {
+		return nil
+	}
+
+	var closed []models.Alert
+	if err := e.db.Select("id", "type").
+		Where("id IN ? AND status <> ?", alertIDs, models.AlertStatusOpen).
+		Find(&closed).Error; err != nil {
+		return err
+	}
+	for _, alert := range closed {
+		delete(e.violations, alertKey{patientID, alert.Type})
+	}
+	return nil
+}
+
+// closeRecovered закриває сповіщення, якщо його ще не закрили вручну.
+func (e *AlertEngine) closeRecovered(alertID uint, at time.Time) {
+	err := e.db.Model(&models.Alert{}).
+		Where("id = ? AND status = ?", alertID, models.AlertStatusOpen).
+		Updates(map[string]interface{}{
+			"status":       models.AlertStatusRecovered,
+			"recovered_at": at,
+		}).Error
+	if err != nil {
+		log.Println("Alert engine: error closing alert:", err)
+	}
+}
@@ -0,0 +1,157 @@
+package telemetry
+
+import (
+	"errors"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Помилки перевірки програми вправ
+var (
+	ErrExerciseNameRequired = errors.New("name is required")
+	ErrExerciseSessions     = errors.New("sessions_per_day is out of range")
+	ErrExerciseNoSteps      = errors.New("program must contain at least one step")
+	ErrExerciseTooManySteps = errors.New("program contains too many steps")
+	ErrExercisePattern      = errors.New("step pattern must be one of: horizontal, vertical, circle, near_far, blink, relax")
+	ErrExerciseColor        = errors.New("step color must be in #RRGGBB format")
+	ErrExerciseBrightness   = errors.New("step brightness must be between 0 and 100")
+	ErrExerciseDuration     = errors.New("step duration_seconds is out of range")
+	ErrExerciseSuperseded   = errors.New("program has been replaced by a newer version")
+)
+
+var exerciseColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// ValidateExerciseProgram перевіряє програму вправ і нумерує кроки в порядку їх передавання.
+func ValidateExerciseProgram(program *models.ExerciseProgram) error {
+	program.Name = strings.TrimSpace(program.Name)
+	if program.Name == "" {
+		return ErrExerciseNameRequired
+	}
+	if program.SessionsPerDay < 1 || program.SessionsPerDay > config.ExerciseMaxSessionsPerDay {
+		return ErrExerciseSessions
+	}
+	if len(program.Steps) == 0 {
+		return ErrExerciseNoSteps
+	}
+	if len(program.Steps) > config.ExerciseMaxSteps {
+		return ErrExerciseTooManySteps
+	}
+
+	for i := range program.Steps {
+		step := &program.Steps[i]
+		switch step.Pattern {
+		case models.ExercisePatternHorizontal, models.ExercisePatternVertical, models.ExercisePatternCircle,
+			models.ExercisePatternNearFar, models.ExercisePatternBlink, models.ExercisePatternRelax:
+		default:
+			return ErrExercisePattern
+		}
+		if step.Color != "" && !exerciseColorPattern.MatchString(step.Color) {
+			return ErrExerciseColor
+		}
+		if step.Brightness < 0 || step.Brightness > 100 {
+			return ErrExerciseBrightness
+		}
+		if step.DurationSeconds < 1 || time.Duration(step.DurationSeconds)*time.Second > config.ExerciseMaxStepDuration {
+			return ErrExerciseDuration
+		}
+		step.ID = 0
+		step.Position = i + 1
+	}
+	return nil
+}
+
+// ActiveExerciseAssignment повертає активне призначення пацієнта разом із програмою та її кроками.
+// Якщо активного призначення немає, повертає nil без помилки.
+func ActiveExerciseAssignment(db *gorm.DB, patientID uint) (*models.ExerciseAssignment, error) {
+	var assignment models.ExerciseAssignment
+	result := db.Preload("Program.Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("patient_id = ? AND ended_at IS NULL", patientID).Limit(1).Find(&assignment)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &assignment, nil
+}
+
+// AdherenceDay — виконання вправ за одну добу.
+type AdherenceDay struct {
+	Date      string `json:"date"`
+	ProgramID *uint  `json:"program_id"` // Програма, що діяла цієї доби; nil — вправи не призначені
+	Expected  int    `json:"expected"`   // Кількість сеансів, передбачена програмою
+	Completed int    `json:"completed"`  // Сеанси, виконані повністю
+	Partial   int    `json:"partial"`    // Розпочаті, але не завершені сеанси
+}
+
+// AdherenceReport — виконання призначених вправ за період.
+type AdherenceReport struct {
+	Timezone      string         `json:"timezone"`
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	Expected      int            `json:"expected"`
+	Completed     int            `json:"completed"` // Без сеансів понад норму доби
+	Partial       int            `json:"partial"`
+	AdherenceRate float64        `json:"adherence_rate"` // Частка виконаних сеансів від передбачених, 0..1
+	Days          []AdherenceDay `json:"days"`
+}
+
+// ComputeExerciseAdherence рахує виконання вправ за доби [from, to) у часовому поясі from.
+// Понад норму виконані сеанси доби до частки виконання не зараховуються.
+func ComputeExerciseAdherence(db *gorm.DB, patientID uint, from, to time.Time) (*AdherenceReport, error) {
+	var assignments []models.ExerciseAssignment
+	if err := db.Preload("Program").
+		Where("patient_id = ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)", patientID, to, from).
+		Order("started_at").
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+
+	var completions []models.ExerciseCompletion
+	if err := db.Where("patient_id = ? AND started_at >= ? AND started_at < ?", patientID, from, to).
+		Order("started_at").
+		Find(&completions).Error; err != nil {
+		return nil, err
+	}
+
+	report := &AdherenceReport{Timezone: from.Location().String(), From: from, To: to, Days: []AdherenceDay{}}
+	for dayStart := from; dayStart.Before(to); dayStart = dayStart.AddDate(0, 0, 1) {
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		day := AdherenceDay{Date: dayStart.Format("2006-01-02")}
+
+		// Якщо протягом доби програму змінили, діє призначена останньою
+		for _, assignment := range assignments {
+			if assignment.StartedAt.Before(dayEnd) && (assignment.EndedAt == nil || assignment.EndedAt.After(dayStart)) && assignment.Program != nil {
+				programID := assignment.ProgramID
+				day.ProgramID = &programID
+				day.Expected = assignment.Program.SessionsPerDay
+			}
+		}
+		for _, completion := range completions {
+			if completion.StartedAt.Before(dayStart) || !completion.StartedAt.Before(dayEnd) {
+				continue
+			}
+			if completion.Completed {
+				day.Completed++
+			} else {
+				day.Partial++
+			}
+		}
+
+		report.Expected += day.Expected
+		report.Completed += min(day.Completed, day.Expected)
+		report.Partial += day.Partial
+		report.Days = append(report.Days, day)
+	}
+
+	if report.Expected > 0 {
+		report.AdherenceRate = float64(report.Completed) / float64(report.Expected)
+	}
+	return report, nil
+}
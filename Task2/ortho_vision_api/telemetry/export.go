@@ -0,0 +1,404 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"strconv"
+	"time"
+
+	"github.com/xitongsys/parquet-go/writer"
+	"gorm.io/gorm"
+)
+
+// Формати й рівні деталізації експорту
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatParquet = "parquet"
+	ExportLevelRaw      = "raw"    // Сирі показники
+	ExportLevelMinute   = "minute" // Похвилинні агрегати
+	ExportLevelHour     = "hour"   // Погодинні агрегати
+)
+
+var (
+	ErrExportFormat = errors.New("format must be one of: csv, parquet")
+	ErrExportLevel  = errors.New("level must be one of: raw, minute, hour")
+)
+
+// ExportQuery описує вибірку показників для експорту. Когорта — це пацієнти, що відповідають
+// усім заданим фільтрам (перелік ID, клініка, діагноз); без фільтрів експортуються всі пацієнти.
+// Агрегати потрапляють у вибірку за часом початку інтервалу.
+// ID пацієнтів і пристроїв у файлі замінено псевдонімами, обчисленими з ключем PseudonymKey.
+type ExportQuery struct {
+	Format       string
+	Level        string
+	PatientIDs   []uint
+	ClinicID     *uint
+	Disease      string
+	From         time.Time
+	To           time.Time
+	PseudonymKey string
+}
+
+// NewPseudonymKey створює випадковий ключ псевдонімів. Псевдоніми з різними ключами
+// не збігаються, тому файли різних експортів не можна зіставити між собою за пацієнтом.
+func NewPseudonymKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// pseudonymizer замінює ID пацієнтів і пристроїв псевдонімами (HMAC-SHA256 з ключем експорту).
+// В межах одного експорту псевдонім сталий, тож показники одного пацієнта можна пов'язати між собою.
+type pseudonymizer struct {
+	key []byte
+}
+
+func (p pseudonymizer) pseudonym(kind string, id uint) string {
+	mac := hmac.New(sha256.New, p.key)
+	fmt.Fprintf(mac, "%s:%d", kind, id)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// ExportQueryOf повертає вибірку фонового завдання експорту.
+func ExportQueryOf(export *models.TelemetryExport) ExportQuery {
+	return ExportQuery{
+		Format:       export.Format,
+		Level:        export.Level,
+		PatientIDs:   export.PatientIDs,
+		ClinicID:     export.ClinicID,
+		Disease:      export.Disease,
+		From:         export.From,
+		To:           export.To,
+		PseudonymKey: export.PseudonymKey,
+	}
+}
+
+// Validate перевіряє формат, рівень деталізації та період (не довший за maxRange).
+func (q ExportQuery) Validate(maxRange time.Duration) error {
+	if q.Format != ExportFormatCSV && q.Format != ExportFormatParquet {
+		return ErrExportFormat
+	}
+	if q.Level != ExportLevelRaw && q.Level != ExportLevelMinute && q.Level != ExportLevelHour {
+		return ErrExportLevel
+	}
+	if !q.From.Before(q.To) {
+		return ErrInvalidRange
+	}
+	if q.To.Sub(q.From) > maxRange {
+		return ErrRangeTooLarge
+	}
+	return nil
+}
+
+// FileName — назва файлу експорту, наприклад telemetry_minute_20240101_20240201.parquet.
+func (q ExportQuery) FileName() string {
+	return fmt.Sprintf("telemetry_%s_%s_%s.%s", q.Level,
+		q.From.UTC().Format("20060102"), q.To.UTC().Format("20060102"), q.Format)
+}
+
+// ContentType — MIME-тип файлу експорту.
+func (q ExportQuery) ContentType() string {
+	if q.Format == ExportFormatParquet {
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// cohort повертає підзапит з ID пацієнтів когорти.
+func (q ExportQuery) cohort(db *gorm.DB) *gorm.DB {
+	patients := db.Model(&models.User{}).Select("id").Where("role = ?", models.RolePatient)
+	if len(q.PatientIDs) > 0 {
+		patients = patients.Where("id IN ?", q.PatientIDs)
+	}
+	if q.ClinicID != nil {
+		patients = patients.Where("clinic_id = ?", *q.ClinicID)
+	}
+	if q.Disease != "" {
+		// Діагнози прив'язані до пацієнта через прийом
+		diagnosed := db.Model(&models.Appointment{}).
+			Select("appointments.patient_id").
+			Joins("JOIN diseases ON diseases.appointment_id = appointments.id").
+			Where("lower(diseases.disease_name) = lower(?)", q.Disease)
+		patients = patients.Where("id IN (?)", diagnosed)
+	}
+	return patients
+}
+
+// rawExportRow — рядок експорту сирих показників.
+type rawExportRow struct {
+	PatientID      string   `parquet:"name=patient_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DeviceID       *string  `parquet:"name=device_id, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	BootID         string   `parquet:"name=boot_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Sequence       *int64   `parquet:"name=sequence, type=INT64, repetitiontype=OPTIONAL"`
+	Timestamp      int64    `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	SchemaVersion  int32    `parquet:"name=schema_version, type=INT32"`
+	PostureAngle   float64  `parquet:"name=posture_angle, type=DOUBLE"`
+	AmbientLux     float64  `parquet:"name=ambient_lux, type=DOUBLE"`
+	ScreenDistance *float64 `parquet:"name=screen_distance_cm, type=DOUBLE, repetitiontype=OPTIONAL"`
+	BlinkRate      *float64 `parquet:"name=blink_rate, type=DOUBLE, repetitiontype=OPTIONAL"`
+	BatteryLevel   *float64 `parquet:"name=battery_level, type=DOUBLE, repetitiontype=OPTIONAL"`
+}
+
+var rawExportHeader = []string{"patient_id", "device_id", "boot_id", "sequence", "timestamp", "schema_version",
+	"posture_angle", "ambient_lux", "screen_distance_cm", "blink_rate", "battery_level"}
+
+func newRawExportRow(reading models.SmartGlassesData, p pseudonymizer) rawExportRow {
+	row := rawExportRow{
+		PatientID:      p.pseudonym("patient", reading.UserID),
+		BootID:         reading.BootID,
+		Sequence:       reading.Sequence,
+		Timestamp:      reading.Timestamp.UnixMilli(),
+		SchemaVersion:  int32(reading.SchemaVersion),
+		PostureAngle:   reading.PostureAngle,
+		AmbientLux:     reading.AmbientLux,
+		ScreenDistance: reading.ScreenDistance,
+		BlinkRate:      reading.BlinkRate,
+		BatteryLevel:   reading.BatteryLevel,
+	}
+	if reading.DeviceID != nil {
+		deviceID := p.pseudonym("device", *reading.DeviceID)
+		row.DeviceID = &deviceID
+	}
+	return row
+}
+
+func (r rawExportRow) record() []string {
+	return []string{
+		r.PatientID, formatOptionalString(r.DeviceID), r.BootID, formatOptionalInt(r.Sequence),
+		formatMillis(r.Timestamp), formatInt(int64(r.SchemaVersion)),
+		formatFloat(r.PostureAngle), formatFloat(r.AmbientLux),
+		formatOptionalFloat(r.ScreenDistance), formatOptionalFloat(r.BlinkRate), formatOptionalFloat(r.BatteryLevel),
+	}
+}
+
+// rollupExportRow — рядок експорту похвилинних або погодинних агрегатів.
+// Тривалість порушень — у секундах, з межами, що діяли для пацієнта під час агрегації.
+type rollupExportRow struct {
+	PatientID            string  `parquet:"name=patient_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BucketStart          int64   `parquet:"name=bucket_start, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Readings             int64   `parquet:"name=readings, type=INT64"`
+	PostureAngleAvg      float64 `parquet:"name=posture_angle_avg, type=DOUBLE"`
+	PostureAngleMin      float64 `parquet:"name=posture_angle_min, type=DOUBLE"`
+	PostureAngleMax      float64 `parquet:"name=posture_angle_max, type=DOUBLE"`
+	AmbientLuxAvg        float64 `parquet:"name=ambient_lux_avg, type=DOUBLE"`
+	AmbientLuxMin        float64 `parquet:"name=ambient_lux_min, type=DOUBLE"`
+	AmbientLuxMax        float64 `parquet:"name=ambient_lux_max, type=DOUBLE"`
+	TimeHeadTiltExceeded float64 `parquet:"name=time_head_tilt_exceeded, type=DOUBLE"`
+	TimeLowLight         float64 `parquet:"name=time_low_light, type=DOUBLE"`
+	TimeHighLight        float64 `parquet:"name=time_high_light, type=DOUBLE"`
+}
+
+var rollupExportHeader = []string{"patient_id", "bucket_start", "readings",
+	"posture_angle_avg", "posture_angle_min", "posture_angle_max",
+	"ambient_lux_avg", "ambient_lux_min", "ambient_lux_max",
+	"time_head_tilt_exceeded", "time_low_light", "time_high_light"}
+
+func newRollupExportRow(rollup models.SmartGlassesRollup, p pseudonymizer) rollupExportRow {
+	row := rollupExportRow{
+		PatientID:            p.pseudonym("patient", rollup.UserID),
+		BucketStart:          rollup.BucketStart.UnixMilli(),
+		Readings:             rollup.Readings,
+		PostureAngleMin:      rollup.PostureAngleMin,
+		PostureAngleMax:      rollup.PostureAngleMax,
+		AmbientLuxMin:        rollup.AmbientLuxMin,
+		AmbientLuxMax:        rollup.AmbientLuxMax,
+		TimeHeadTiltExceeded: rollup.TimeHeadTiltExceeded,
+		TimeLowLight:         rollup.TimeLowLight,
+		TimeHighLight:        rollup.TimeHighLight,
+	}
+	if rollup.Readings > 0 {
+		row.PostureAngleAvg = rollup.PostureAngleSum / float64(rollup.Readings)
+		row.AmbientLuxAvg = rollup.AmbientLuxSum / float64(rollup.Readings)
+	}
+	return row
+}
+
+func (r rollupExportRow) record() []string {
+	return []string{
+		r.PatientID, formatMillis(r.BucketStart), formatInt(r.Readings),
+		formatFloat(r.PostureAngleAvg), formatFloat(r.PostureAngleMin), formatFloat(r.PostureAngleMax),
+		formatFloat(r.AmbientLuxAvg), formatFloat(r.AmbientLuxMin), formatFloat(r.AmbientLuxMax),
+		formatFloat(r.TimeHeadTiltExceeded), formatFloat(r.TimeLowLight), formatFloat(r.TimeHighLight),
+	}
+}
+
+// exportRow — рядок експорту, який можна записати у CSV (і в Parquet — за тегами структури).
+type exportRow interface {
+	record() []string
+}
+
+// rowWriter записує рядки експорту у вихідний потік.
+type rowWriter interface {
+	write(row exportRow) error
+	close() error
+}
+
+// csvRowWriter пише CSV із рядком заголовка. Час — у форматі RFC 3339 (UTC).
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvRowWriter) write(row exportRow) error {
+	return w.w.Write(row.record())
+}
+
+func (w *csvRowWriter) close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// parquetRowWriter пише Parquet. Рядки накопичуються в пам'яті до розміру групи
+// config.ExportParquetRowGroup, після чого група записується у потік.
+type parquetRowWriter struct {
+	w *writer.ParquetWriter
+}
+
+func (w *parquetRowWriter) write(row exportRow) error {
+	return w.w.Write(row)
+}
+
+func (w *parquetRowWriter) close() error {
+	return w.w.WriteStop()
+}
+
+// newRowWriter створює записувач для формату й рівня деталізації вибірки.
+func newRowWriter(q ExportQuery, out io.Writer) (rowWriter, error) {
+	var schema interface{} = new(rollupExportRow)
+	header := rollupExportHeader
+	if q.Level == ExportLevelRaw {
+		schema = new(rawExportRow)
+		header = rawExportHeader
+	}
+
+	if q.Format == ExportFormatParquet {
+		pw, err := writer.NewParquetWriterFromWriter(out, schema, 1)
+		if err != nil {
+			return nil, err
+		}
+		pw.RowGroupSize = config.ExportParquetRowGroup
+		return &parquetRowWriter{w: pw}, nil
+	}
+
+	w := csv.NewWriter(out)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{w: w}, nil
+}
+
+// Export пише показники вибірки у out, не завантажуючи їх у пам'ять повністю:
+// рядки читаються з бази даних курсором і одразу записуються. Рядки впорядковано
+// за пацієнтом і часом. Якщо ключ псевдонімів не задано, використовується випадковий.
+// Після скасування ctx експорт припиняється з помилкою ctx.Err().
+// Повертає кількість записаних рядків.
+func Export(ctx context.Context, db *gorm.DB, q ExportQuery, out io.Writer) (int64, error) {
+	if q.PseudonymKey == "" {
+		key, err := NewPseudonymKey()
+		if err != nil {
+			return 0, err
+		}
+		q.PseudonymKey = key
+	}
+	p := pseudonymizer{key: []byte(q.PseudonymKey)}
+
+	w, err := newRowWriter(q, out)
+	if err != nil {
+		return 0, err
+	}
+
+	db = db.WithContext(ctx)
+
+	var query *gorm.DB
+	switch q.Level {
+	case ExportLevelRaw:
+		query = db.Model(&models.SmartGlassesData{}).
+			Where(`"timestamp" >= ? AND "timestamp" < ?`, q.From, q.To).
+			Order(`user_id, "timestamp"`)
+	case ExportLevelMinute:
+		query = db.Model(&models.SmartGlassesMinuteRollup{}).
+			Where("bucket_start >= ? AND bucket_start < ?", q.From, q.To).
+			Order("user_id, bucket_start")
+	default:
+		query = db.Model(&models.SmartGlassesHourRollup{}).
+			Where("bucket_start >= ? AND bucket_start < ?", q.From, q.To).
+			Order("user_id, bucket_start")
+	}
+	rows, err := query.Where("user_id IN (?)", q.cohort(db)).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		var row exportRow
+		if q.Level == ExportLevelRaw {
+			var reading models.SmartGlassesData
+			if err := db.ScanRows(rows, &reading); err != nil {
+				return count, err
+			}
+			row = newRawExportRow(reading, p)
+		} else {
+			var rollup models.SmartGlassesRollup
+			if err := db.ScanRows(rows, &rollup); err != nil {
+				return count, err
+			}
+			row = newRollupExportRow(rollup, p)
+		}
+		if err := w.write(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, w.close()
+}
+
+func formatInt(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
+
+func formatOptionalInt(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return formatInt(*value)
+}
+
+func formatOptionalString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value)
+}
+
+func formatMillis(value int64) string {
+	return time.UnixMilli(value).UTC().Format(time.RFC3339Nano)
+}
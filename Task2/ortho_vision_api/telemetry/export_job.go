@@ -0,0 +1,271 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExportJob у фоні виконує завдання експорту показників у файли в config.ExportDir
+// і видаляє файли, термін зберігання яких минув.
+type ExportJob struct {
+	db     *gorm.DB
+	stop   chan struct{}
+	ctx    context.Context // Скасовується під час зупинки, щоб перервати поточний експорт
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+}
+
+// NewExportJob створює ExportJob. Щоб почати роботу, потрібно викликати Start.
+func NewExportJob(db *gorm.DB) *ExportJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ExportJob{
+		db:     db,
+		stop:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start запускає виконання завдань з інтервалом config.ExportInterval.
+func (j *ExportJob) Start() error {
+	if err := os.MkdirAll(config.ExportDir, 0o750); err != nil {
+		return err
+	}
+
+	j.done.Add(1)
+	go func() {
+		defer j.done.Done()
+		ticker := time.NewTicker(config.ExportInterval)
+		defer ticker.Stop()
+		for {
+			j.RunOnce()
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop зупиняє фонову роботу. Поточний експорт переривається, а завдання повертається
+// в чергу, щоб виконатися знову після перезапуску.
+func (j *ExportJob) Stop() {
+	close(j.stop)
+	j.cancel()
+	j.done.Wait()
+}
+
+// RunOnce видаляє застарілі файли й виконує всі завдання, що чекають на виконання.
+func (j *ExportJob) RunOnce() {
+	if err := j.expire(time.Now()); err != nil {
+		log.Println("Export cleanup error:", err)
+	}
+
+	for {
+		select {
+		case <-j.stop:
+			return
+		default:
+		}
+
+		export, err := j.claim(time.Now())
+		if err != nil {
+			log.Println("Export error:", err)
+			return
+		}
+		if export == nil {
+			return
+		}
+		j.run(export)
+	}
+}
+
+// claim позначає найстаріше завдання, що чекає на виконання (або перерване), як виконуване
+// і повертає його. SKIP LOCKED не дає кільком екземплярам сервера взяти одне завдання.
+// Завдання вважається перерваним, якщо його виконавець довше за config.ExportStaleAfter
+// не оновлював heartbeat_at.
+func (j *ExportJob) claim(now time.Time) (*models.TelemetryExport, error) {
+	var export models.TelemetryExport
+	err := j.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND COALESCE(heartbeat_at, started_at) < ?)",
+				models.ExportStatusPending, models.ExportStatusRunning, now.Add(-config.ExportStaleAfter)).
+			Order("id").
+			First(&export).Error
+		if err != nil {
+			return err
+		}
+		export.Status = models.ExportStatusRunning
+		export.StartedAt = &now
+		export.HeartbeatAt = &now
+		updates := map[string]interface{}{
+			"status":       export.Status,
+			"started_at":   export.StartedAt,
+			"heartbeat_at": export.HeartbeatAt,
+		}
+		// Завдання, створені до появи псевдонімів, отримують ключ під час першого запуску
+		if export.PseudonymKey == "" {
+			key, err := NewPseudonymKey()
+			if err != nil {
+				return err
+			}
+			export.PseudonymKey = key
+			updates["pseudonym_key"] = key
+		}
+		return tx.Model(&export).Updates(updates).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// run створює файл завдання і зберігає результат. Файл спершу пишеться під тимчасовою
+// назвою, тому незавершений файл ніколи не буде завантажено.
+func (j *ExportJob) run(export *models.TelemetryExport) {
+	query := ExportQueryOf(export)
+	path := filepath.Join(config.ExportDir, fmt.Sprintf("telemetry-export-%d.%s", export.ID, query.Format))
+
+	stopHeartbeat := j.heartbeat(export)
+	rows, size, err := writeExportFile(j.ctx, j.db, query, path)
+	stopHeartbeat()
+	if errors.Is(err, context.Canceled) {
+		// Сервер зупиняється: повертаємо завдання в чергу
+		if err := j.db.Model(export).Updates(map[string]interface{}{
+			"status":       models.ExportStatusPending,
+			"started_at":   nil,
+			"heartbeat_at": nil,
+		}).Error; err != nil {
+			log.Println("Export error for job", export.ID, ":", err)
+		}
+		return
+	}
+	if err != nil {
+		log.Println("Export error for job", export.ID, ":", err)
+		if err := j.db.Model(export).Updates(map[string]interface{}{
+			"status": models.ExportStatusFailed,
+			"error":  "Failed to export telemetry",
+		}).Error; err != nil {
+			log.Println("Export error for job", export.ID, ":", err)
+		}
+		return
+	}
+
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(config.ExportFileTTL)
+	if err := j.db.Model(export).Updates(map[string]interface{}{
+		"status":       models.ExportStatusCompleted,
+		"rows":         rows,
+		"size_bytes":   size,
+		"file_path":    path,
+		"completed_at": completedAt,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		log.Println("Export error for job", export.ID, ":", err)
+	}
+}
+
+// heartbeat кожні config.ExportHeartbeat оновлює heartbeat_at завдання, щоб інші екземпляри
+// сервера не вважали його перерваним. Повертає функцію, що зупиняє оновлення.
+func (j *ExportJob) heartbeat(export *models.TelemetryExport) func() {
+	stop := make(chan struct{})
+	var done sync.WaitGroup
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		ticker := time.NewTicker(config.ExportHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := j.db.Model(&models.TelemetryExport{}).
+					Where("id = ? AND status = ?", export.ID, models.ExportStatusRunning).
+					Update("heartbeat_at", time.Now()).Error; err != nil {
+					log.Println("Export heartbeat error for job", export.ID, ":", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		done.Wait()
+	}
+}
+
+// writeExportFile пише вибірку у файл path і повертає кількість рядків і розмір файлу.
+func writeExportFile(ctx context.Context, db *gorm.DB, query ExportQuery, path string) (int64, int64, error) {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	rows, err := Export(ctx, db, query, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, 0, err
+	}
+	return rows, info.Size(), nil
+}
+
+// expire видаляє файли завершених завдань, термін зберігання яких минув.
+func (j *ExportJob) expire(now time.Time) error {
+	var expired []models.TelemetryExport
+	if err := j.db.Where("status = ? AND expires_at < ?", models.ExportStatusCompleted, now).
+		Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, export := range expired {
+		if err := RemoveExportFile(&export); err != nil {
+			log.Println("Export cleanup error for job", export.ID, ":", err)
+			continue
+		}
+		if err := j.db.Model(&export).Updates(map[string]interface{}{
+			"status":    models.ExportStatusExpired,
+			"file_path": "",
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveExportFile видаляє файл завдання експорту, якщо він є.
+func RemoveExportFile(export *models.TelemetryExport) error {
+	if export.FilePath == "" {
+		return nil
+	}
+	if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
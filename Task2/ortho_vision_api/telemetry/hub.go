@@ -0,0 +1,116 @@
+package telemetry
+
+import (
+	"ortho_vision_api/models"
+	"sync"
+	"sync/atomic"
+)
+
+// Hub розсилає щойно збережені показники всім підписникам пацієнта в межах процесу.
+// Публікація ніколи не блокується: якщо черга підписника заповнена, показник для нього
+// відкидається і враховується в лічильнику Dropped.
+type Hub struct {
+	buffer int
+
+	mu          sync.RWMutex
+	subscribers map[uint]map[*Subscription]struct{}
+	listeners   []func([]models.SmartGlassesData)
+}
+
+// Subscription — підписка на показники одного пацієнта.
+type Subscription struct {
+	PatientID uint
+
+	readings chan models.SmartGlassesData
+	dropped  atomic.Int64
+}
+
+// NewHub створює Hub з чергою вказаного розміру для кожного підписника.
+func NewHub(buffer int) *Hub {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return &Hub{
+		buffer:      buffer,
+		subscribers: make(map[uint]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe створює підписку на показники пацієнта. Після завершення потрібно викликати Unsubscribe.
+func (h *Hub) Subscribe(patientID uint) *Subscription {
+	sub := &Subscription{
+		PatientID: patientID,
+		readings:  make(chan models.SmartGlassesData, h.buffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[patientID] == nil {
+		h.subscribers[patientID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[patientID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe видаляє підписку і закриває її канал. Повторний виклик нічого не робить.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.subscribers[sub.PatientID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.PatientID)
+	}
+	close(sub.readings)
+}
+
+// AddListener додає обробник, який отримує всі збережені показники (наприклад, перевірку сповіщень).
+// На відміну від підписників, обробники викликаються для кожного пакета і можуть блокувати публікацію,
+// тому повинні лише ставити показники в чергу.
+func (h *Hub) AddListener(listener func([]models.SmartGlassesData)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, listener)
+}
+
+// Publish розсилає показники підписникам відповідних пацієнтів. Для nil Hub нічого не робить.
+func (h *Hub) Publish(readings []models.SmartGlassesData) {
+	if h == nil {
+		return
+	}
+
+	// Обробники викликаються поза блокуванням, щоб повна черга не заважала підписці глядачів
+	h.mu.RLock()
+	listeners := h.listeners
+	h.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(readings)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, reading := range readings {
+		for sub := range h.subscribers[reading.UserID] {
+			select {
+			case sub.readings <- reading:
+			default:
+				// Повільний глядач не повинен гальмувати отримання показників
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Readings повертає канал показників; канал закривається після Unsubscribe.
+func (s *Subscription) Readings() <-chan models.SmartGlassesData {
+	return s.readings
+}
+
+// TakeDropped повертає кількість відкинутих показників з моменту попереднього виклику.
+func (s *Subscription) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
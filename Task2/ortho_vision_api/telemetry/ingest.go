@@ -0,0 +1,224 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Статуси обробки окремого показника
+const (
+	StatusAccepted  = "accepted"
+	StatusDuplicate = "duplicate" // Повтор уже збереженого показника; приймається без помилки
+	StatusRejected  = "rejected"
+)
+
+// Result — результат обробки одного показника з пакета.
+type Result struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     uint   `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// DecodeBatch розбиває тіло запиту на окремі показники.
+// Тіло може бути JSON-масивом або NDJSON (один JSON-об'єкт у кожному рядку).
+func DecodeBatch(body []byte, ndjson bool) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if ndjson {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, json.RawMessage(append([]byte(nil), line...)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, ErrInvalidBatchFormat
+		}
+	} else if err := json.Unmarshal(body, &items); err != nil {
+		return nil, ErrInvalidBatchFormat
+	}
+
+	if len(items) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(items) > config.TelemetryMaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+	return items, nil
+}
+
+// Ingest перевіряє кожен показник, зберігає всі коректні одним запитом INSERT
+// і повертає результат для кожного елемента в порядку надходження.
+// Показники зберігаються з часом, указаним пристроєм.
+func Ingest(db *gorm.DB, hub *Hub, device *models.SmartGlassesDevice, items []json.RawMessage) ([]Result, error) {
+	now := time.Now()
+	results := make([]Result, len(items))
+	readings := make([]models.SmartGlassesData, 0, len(items))
+	positions := make([]int, 0, len(items)) // Індекс у пакеті для кожного прийнятого показника
+
+	for i, item := range items {
+		results[i] = Result{Index: i, Status: StatusRejected}
+
+		reading, err := DecodeReading(item)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if err := Validate(reading, now); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		readings = append(readings, reading)
+		positions = append(positions, i)
+	}
+
+	duplicates, err := Store(db, hub, device, readings)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, reading := range readings {
+		results[positions[j]].Status = StatusAccepted
+		if duplicates[j] {
+			results[positions[j]].Status = StatusDuplicate
+		}
+		results[positions[j]].ID = reading.ID
+	}
+	return results, nil
+}
+
+// Store зберігає вже перевірені показники для пацієнта, до якого прив'язаний пристрій,
+// одним запитом INSERT, оновлює час останнього зв'язку з пристроєм
+// і публікує збережені показники в hub для перегляду в реальному часі.
+// Показник із номером послідовності, який пристрій уже надсилав у межах того самого boot_id,
+// повторно не зберігається: він отримує ID збереженого раніше показника і позначається
+// в повернутому зрізі як повтор.
+func Store(db *gorm.DB, hub *Hub, device *models.SmartGlassesDevice, readings []models.SmartGlassesData) ([]bool, error) {
+	duplicates := make([]bool, len(readings))
+	if len(readings) == 0 {
+		return duplicates, nil
+	}
+
+	for i := range readings {
+		readings[i].ID = 0
+		readings[i].UserID = *device.PatientID
+		readings[i].DeviceID = &device.ID
+	}
+
+	var fresh []models.SmartGlassesData
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Блокуємо пристрій, щоб паралельні запити не зберегли той самий номер послідовності двічі
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.SmartGlassesDevice{}, device.ID).Error; err != nil {
+			return err
+		}
+
+		stored, err := storedSequences(tx, device.ID, readings)
+		if err != nil {
+			return err
+		}
+
+		fresh = make([]models.SmartGlassesData, 0, len(readings))
+		positions := make([]int, 0, len(readings))
+		firstInBatch := make(map[sequenceKey]int) // Номер послідовності → індекс першого показника з ним у пакеті
+		for i, reading := range readings {
+			if reading.Sequence != nil {
+				key := sequenceKeyOf(reading)
+				if id, found := stored[key]; found {
+					duplicates[i] = true
+					readings[i].ID = id
+					continue
+				}
+				if _, found := firstInBatch[key]; found {
+					duplicates[i] = true
+					continue
+				}
+				firstInBatch[key] = i
+			}
+			fresh = append(fresh, reading)
+			positions = append(positions, i)
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+
+		// Показники і позначка для агрегації зберігаються разом, щоб жоден показник не пропустити в агрегатах
+		earliest := fresh[0].Timestamp
+		for _, reading := range fresh[1:] {
+			if reading.Timestamp.Before(earliest) {
+				earliest = reading.Timestamp
+			}
+		}
+		if err := tx.Create(&fresh).Error; err != nil {
+			return err
+		}
+		for j, reading := range fresh {
+			readings[positions[j]].ID = reading.ID
+		}
+		// Повтори всередині пакета отримують ID першого показника з тим самим номером
+		for i, reading := range readings {
+			if duplicates[i] && reading.ID == 0 {
+				readings[i].ID = readings[firstInBatch[sequenceKeyOf(reading)]].ID
+			}
+		}
+		return markRollupPending(tx, *device.PatientID, earliest)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(fresh) > 0 {
+		hub.Publish(fresh)
+	}
+
+	// Показники вже збережено, тому помилку оновлення лише записуємо в журнал
+	if err := db.Model(device).Update("last_seen_at", time.Now()).Error; err != nil {
+		log.Println("Error updating device last seen:", err)
+	}
+	return duplicates, nil
+}
+
+// sequenceKey — номер послідовності показника в межах запуску прошивки.
+type sequenceKey struct {
+	bootID   string
+	sequence int64
+}
+
+func sequenceKeyOf(reading models.SmartGlassesData) sequenceKey {
+	return sequenceKey{bootID: reading.BootID, sequence: *reading.Sequence}
+}
+
+// storedSequences повертає ID уже збережених показників пристрою з номерами послідовності з readings.
+func storedSequences(db *gorm.DB, deviceID uint, readings []models.SmartGlassesData) (map[sequenceKey]uint, error) {
+	keys := make([][]interface{}, 0, len(readings))
+	for _, reading := range readings {
+		if reading.Sequence != nil {
+			keys = append(keys, []interface{}{reading.BootID, *reading.Sequence})
+		}
+	}
+	stored := make(map[sequenceKey]uint)
+	if len(keys) == 0 {
+		return stored, nil
+	}
+
+	var rows []models.SmartGlassesData
+	if err := db.Select("id", "boot_id", "sequence").
+		Where("device_id = ? AND (boot_id, sequence) IN ?", deviceID, keys).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stored[sequenceKeyOf(row)] = row.ID
+	}
+	return stored, nil
+}
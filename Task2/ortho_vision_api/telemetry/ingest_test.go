@@ -0,0 +1,39 @@
+package telemetry
+
+import (
+	"ortho_vision_api/config"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBatch(t *testing.T) {
+	tooLarge := "[" + strings.TrimSuffix(strings.Repeat(`{"posture_angle":10},`, config.TelemetryMaxBatchSize+1), ",") + "]"
+
+	tests := []struct {
+		name   string
+		body   string
+		ndjson bool
+		items  int
+		err    error
+	}{
+		{"json array", `[{"posture_angle":10},{"posture_angle":20}]`, false, 2, nil},
+		{"ndjson", "{\"posture_angle\":10}\n{\"posture_angle\":20}\n", true, 2, nil},
+		{"ndjson with blank lines", "\n{\"posture_angle\":10}\r\n\n  \n{\"posture_angle\":20}", true, 2, nil},
+		{"empty array", `[]`, false, 0, ErrBatchEmpty},
+		{"empty ndjson", "\n\n", true, 0, ErrBatchEmpty},
+		{"object instead of array", `{"posture_angle":10}`, false, 0, ErrInvalidBatchFormat},
+		{"invalid json", `[{"posture_angle":`, false, 0, ErrInvalidBatchFormat},
+		{"too many readings", tooLarge, false, 0, ErrBatchTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := DecodeBatch([]byte(tt.body), tt.ndjson)
+			if err != tt.err {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if len(items) != tt.items {
+				t.Fatalf("got %d items, want %d", len(items), tt.items)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gorm.io/gorm"
+)
+
+// Суфікси топіків пристрою
+const (
+	telemetryTopic = "telemetry" // Пристрій публікує показники
+	ackTopic       = "ack"       // Сервер публікує результати обробки
+)
+
+// Subscriber отримує показники смарт-окулярів із брокера MQTT.
+// Кожен пристрій публікує в топік <prefix>/<серійний номер>/telemetry повідомлення
+//
+//	{"device_key": "<API-ключ пристрою>", "readings": ...}
+//
+// де readings — один показник або масив показників у форматі версії 1 чи 2 (див. payload.go),
+// як у POST /smart-glasses/batch. На відміну від POST /smart-glasses, поле timestamp обов'язкове:
+// повідомлення може надійти із затримкою, тому час отримання замість часу пристрою не підставляється.
+// Серійний номер не є секретом, тому пристрій, як і в HTTP, підтверджує себе API-ключем
+// у полі device_key: повідомлення приймається, лише якщо ключ належить пристрою з топіка.
+// Перевірка і збереження спільні з HTTP (Validate, Ingest).
+type Subscriber struct {
+	db     *gorm.DB
+	hub    *Hub
+	prefix string
+	client mqtt.Client
+}
+
+// NewSubscriberFromConfig створює Subscriber відповідно до налаштувань.
+// Збережені показники публікуються в hub. Якщо брокер не налаштовано, повертає nil.
+func NewSubscriberFromConfig(db *gorm.DB, hub *Hub) *Subscriber {
+	if config.MQTTBrokerURL == "" {
+		return nil
+	}
+
+	s := &Subscriber{
+		db:     db,
+		hub:    hub,
+		prefix: strings.TrimSuffix(config.MQTTTopicPrefix, "/"),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.MQTTBrokerURL).
+		SetClientID(config.MQTTClientID + "-" + config.MQTTInstanceID).
+		SetUsername(config.MQTTUsername).
+		SetPassword(config.MQTTPassword).
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOrderMatters(false)
+	// Підписуємося під час кожного з'єднання, щоб підписка відновлювалася після перепідключення
+	opts.SetOnConnectHandler(s.subscribe)
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Println("MQTT connection lost:", err)
+	})
+
+	s.client = mqtt.NewClient(opts)
+	return s
+}
+
+// Start підключається до брокера. З'єднання встановлюється у фоні,
+// тому сервер запускається навіть тоді, коли брокер тимчасово недоступний.
+func (s *Subscriber) Start() {
+	s.client.Connect()
+	log.Println("MQTT subscriber started for", config.MQTTBrokerURL)
+}
+
+// Stop відключається від брокера, даючи завершитися обробці поточних повідомлень.
+func (s *Subscriber) Stop() {
+	s.client.Disconnect(1000)
+}
+
+// subscribe підписується на топіки показників усіх пристроїв. Підписка спільна для всіх
+// екземплярів сервера, тому кожне повідомлення обробляється один раз.
+func (s *Subscriber) subscribe(client mqtt.Client) {
+	topic := "$share/" + config.MQTTSharedGroup + "/" + s.prefix + "/+/" + telemetryTopic
+	token := client.Subscribe(topic, 1, s.handleMessage)
+	if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		log.Println("MQTT subscribe error:", token.Error())
+		return
+	}
+	log.Println("MQTT subscribed to", topic)
+}
+
+// handleMessage обробляє одне повідомлення з показниками пристрою.
+func (s *Subscriber) handleMessage(client mqtt.Client, msg mqtt.Message) {
+	serialNumber, ok := s.serialFromTopic(msg.Topic())
+	if !ok {
+		return
+	}
+
+	deviceKey, items, err := decodeMessage(msg.Payload())
+	if err != nil {
+		s.publishAck(client, serialNumber, map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	device, err := authenticateMQTTDevice(s.db, serialNumber, deviceKey)
+	if err != nil {
+		if err == auth.ErrInvalidDeviceKey {
+			log.Println("MQTT message with invalid device key for", serialNumber)
+			s.publishAck(client, serialNumber, map[string]interface{}{"error": "Invalid or missing device key"})
+			return
+		}
+		log.Println("MQTT error authenticating device:", err)
+		s.publishAck(client, serialNumber, map[string]interface{}{"error": "Error authenticating device"})
+		return
+	}
+	if !device.IsPaired() {
+		s.publishAck(client, serialNumber, map[string]interface{}{"error": "Device is not paired with a patient"})
+		return
+	}
+	if err := auth.CheckDevicePatient(s.db, device); err != nil {
+		if err == auth.ErrPatientDeactivated {
+			s.publishAck(client, serialNumber, map[string]interface{}{"error": "Patient account is deactivated"})
+			return
+		}
+		log.Println("MQTT error checking device patient:", err)
+		s.publishAck(client, serialNumber, map[string]interface{}{"error": "Error authenticating device"})
+		return
+	}
+
+	results, err := Ingest(s.db, s.hub, device, items)
+	if err != nil {
+		log.Println("MQTT error storing smart glasses data:", err)
+		s.publishAck(client, serialNumber, map[string]interface{}{"error": "Failed to create smart glasses data"})
+		return
+	}
+	s.publishAck(client, serialNumber, map[string]interface{}{"results": results})
+}
+
+// publishAck надсилає пристрою результат обробки повідомлення.
+func (s *Subscriber) publishAck(client mqtt.Client, serialNumber string, payload map[string]interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("MQTT ack encoding error:", err)
+		return
+	}
+	client.Publish(s.prefix+"/"+serialNumber+"/"+ackTopic, 0, false, body)
+}
+
+// serialFromTopic витягує серійний номер пристрою з топіка <prefix>/<serial>/telemetry.
+func (s *Subscriber) serialFromTopic(topic string) (string, bool) {
+	rest, found := strings.CutPrefix(topic, s.prefix+"/")
+	if !found {
+		return "", false
+	}
+	serialNumber, found := strings.CutSuffix(rest, "/"+telemetryTopic)
+	if !found || serialNumber == "" || strings.Contains(serialNumber, "/") {
+		return "", false
+	}
+	return serialNumber, true
+}
+
+// Помилки розбору повідомлення MQTT
+var (
+	ErrInvalidMessage  = errors.New("message must be a JSON object with device_key and readings")
+	ErrMissingReadings = errors.New("readings are required")
+)
+
+// mqttMessage — повідомлення пристрою: API-ключ і показники.
+type mqttMessage struct {
+	DeviceKey string          `json:"device_key"`
+	Readings  json.RawMessage `json:"readings"`
+}
+
+// decodeMessage розбирає повідомлення пристрою. Показники — один JSON-об'єкт або масив.
+func decodeMessage(payload []byte) (string, []json.RawMessage, error) {
+	var message mqttMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return "", nil, ErrInvalidMessage
+	}
+	readings := bytes.TrimSpace(message.Readings)
+	if len(readings) == 0 || bytes.Equal(readings, []byte("null")) {
+		return "", nil, ErrMissingReadings
+	}
+	if readings[0] == '{' {
+		return message.DeviceKey, []json.RawMessage{json.RawMessage(readings)}, nil
+	}
+	items, err := DecodeBatch(readings, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return message.DeviceKey, items, nil
+}
+
+// authenticateMQTTDevice знаходить активний пристрій за API-ключем і перевіряє,
+// що ключ належить саме пристрою з топіка. Інакше повертає auth.ErrInvalidDeviceKey.
+func authenticateMQTTDevice(db *gorm.DB, serialNumber, deviceKey string) (*models.SmartGlassesDevice, error) {
+	device, err := auth.AuthenticateDevice(db, deviceKey)
+	if err != nil {
+		return nil, err
+	}
+	if device.SerialNumber != serialNumber {
+		return nil, auth.ErrInvalidDeviceKey
+	}
+	return device, nil
+}
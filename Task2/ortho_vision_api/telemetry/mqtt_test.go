@@ -0,0 +1,270 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"ortho_vision_api/auth"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"os"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	mqttauth "github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDecodeMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		key     string
+		items   int
+		err     error
+	}{
+		{"single reading", `{"device_key":"k","readings":{"posture_angle":10,"ambient_lux":300}}`, "k", 1, nil},
+		{"batch", `{"device_key":"k","readings":[{"posture_angle":10},{"posture_angle":20}]}`, "k", 2, nil},
+		{"missing key is left to authentication", `{"readings":[{"posture_angle":10}]}`, "", 1, nil},
+		{"missing readings", `{"device_key":"k"}`, "", 0, ErrMissingReadings},
+		{"null readings", `{"device_key":"k","readings":null}`, "", 0, ErrMissingReadings},
+		{"empty batch", `{"device_key":"k","readings":[]}`, "", 0, ErrBatchEmpty},
+		{"bare reading without envelope", `[{"posture_angle":10}]`, "", 0, ErrInvalidMessage},
+		{"invalid json", `{"device_key":`, "", 0, ErrInvalidMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, items, err := decodeMessage([]byte(tt.payload))
+			if err != tt.err {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if key != tt.key || len(items) != tt.items {
+				t.Fatalf("got key %q and %d items, want %q and %d", key, len(items), tt.key, tt.items)
+			}
+		})
+	}
+}
+
+// TestSubscriberRoundTrip перевіряє обробку повідомлень через вбудований брокер MQTT.
+// Потрібна база даних PostgreSQL, задана змінною TEST_DATABASE_URL; без неї тест пропускається.
+func TestSubscriberRoundTrip(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.SmartGlassesDevice{}, &models.SmartGlassesData{}, &models.SmartGlassesRollupPending{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Пацієнт із прив'язаним пристроєм і другий пристрій, ключем якого спробують надіслати чужі показники
+	suffix := uuid.NewString()
+	patient := models.User{Name: "MQTT test", Email: "mqtt-" + suffix + "@example.com", PasswordHash: "-", Role: models.RolePatient}
+	if err := db.Create(&patient).Error; err != nil {
+		t.Fatal(err)
+	}
+	deviceKey, otherKey := "key-"+suffix, "other-"+suffix
+	device := models.SmartGlassesDevice{SerialNumber: "SG-" + suffix, PatientID: &patient.ID, APIKeyHash: auth.HashToken(deviceKey)}
+	other := models.SmartGlassesDevice{SerialNumber: "SG-other-" + suffix, PatientID: &patient.ID, APIKeyHash: auth.HashToken(otherKey)}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("user_id = ?", patient.ID).Delete(&models.SmartGlassesData{})
+		db.Delete(&models.SmartGlassesRollupPending{}, patient.ID)
+		db.Delete(&models.SmartGlassesDevice{}, []uint{device.ID, other.ID})
+		db.Delete(&patient)
+	})
+
+	broker := startBroker(t)
+	prefix := "test/" + suffix
+	config.MQTTBrokerURL = broker
+	config.MQTTClientID = "subscriber-" + suffix
+	config.MQTTTopicPrefix = prefix
+	subscriber := NewSubscriberFromConfig(db, nil)
+	subscriber.Start()
+	t.Cleanup(subscriber.Stop)
+
+	client := connectClient(t, broker, "device-"+suffix)
+	acks := make(chan ack, 100)
+	token := client.Subscribe(prefix+"/+/ack", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		var a ack
+		if err := json.Unmarshal(msg.Payload(), &a); err != nil {
+			t.Errorf("invalid ack %s: %v", msg.Payload(), err)
+		}
+		a.Topic = msg.Topic()
+		acks <- a
+	})
+	if token.Wait(); token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	reading := fmt.Sprintf(`{"schema_version":2,"sequence":1,"posture_angle":20,"ambient_lux":300,"timestamp":%q}`, timestamp)
+	probe := fmt.Sprintf(`{"schema_version":2,"boot_id":"probe","sequence":1,"posture_angle":20,"ambient_lux":300,"timestamp":%q}`, timestamp)
+	message := func(key, readings string) string {
+		return fmt.Sprintf(`{"device_key":%q,"readings":%s}`, key, readings)
+	}
+
+	// Підписка сервера встановлюється у фоні, тому пробний показник повторюємо, доки не прийде відповідь
+	probeID := waitForSubscriber(t, client, prefix+"/"+device.SerialNumber+"/telemetry", message(deviceKey, probe), acks)
+
+	publish := func(serialNumber, payload string) ack {
+		t.Helper()
+		client.Publish(prefix+"/"+serialNumber+"/telemetry", 1, false, payload).Wait()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case a := <-acks:
+				// Відповіді на пробні показники, повторені під час очікування підписки, можуть прийти пізніше
+				if len(a.Results) == 1 && a.Results[0].ID == probeID {
+					continue
+				}
+				if want := prefix + "/" + serialNumber + "/ack"; a.Topic != want {
+					t.Fatalf("ack topic = %s, want %s", a.Topic, want)
+				}
+				return a
+			case <-timeout:
+				t.Fatal("no ack received")
+				return ack{}
+			}
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		a := publish(device.SerialNumber, message(deviceKey, `[`+reading+`,{"posture_angle":20}]`))
+		if a.Error != "" || len(a.Results) != 2 {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+		if a.Results[0].Status != StatusAccepted || a.Results[1].Status != StatusRejected {
+			t.Fatalf("unexpected results: %+v", a.Results)
+		}
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		a := publish(device.SerialNumber, message(deviceKey, reading))
+		if len(a.Results) != 1 || a.Results[0].Status != StatusDuplicate {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+		var count int64
+		db.Model(&models.SmartGlassesData{}).Where("device_id = ? AND boot_id = ''", device.ID).Count(&count)
+		if count != 1 {
+			t.Fatalf("stored %d readings, want 1", count)
+		}
+	})
+
+	t.Run("unknown serial", func(t *testing.T) {
+		a := publish("SG-unknown-"+suffix, message(deviceKey, reading))
+		if a.Error != "Invalid or missing device key" {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+	})
+
+	t.Run("key of another device", func(t *testing.T) {
+		a := publish(device.SerialNumber, message(otherKey, reading))
+		if a.Error != "Invalid or missing device key" {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		a := publish(device.SerialNumber, `{"readings":`+reading+`}`)
+		if a.Error != "Invalid or missing device key" {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		a := publish(device.SerialNumber, `not json`)
+		if a.Error != ErrInvalidMessage.Error() {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+	})
+
+	t.Run("deactivated patient", func(t *testing.T) {
+		if err := db.Model(&patient).Update("deactivated_at", time.Now()).Error; err != nil {
+			t.Fatal(err)
+		}
+		a := publish(device.SerialNumber, message(deviceKey, reading))
+		if a.Error != "Patient account is deactivated" {
+			t.Fatalf("unexpected ack: %+v", a)
+		}
+	})
+}
+
+// ack — відповідь сервера в топіку <prefix>/<серійний номер>/ack.
+type ack struct {
+	Topic   string
+	Results []Result `json:"results"`
+	Error   string   `json:"error"`
+}
+
+// startBroker запускає вбудований брокер MQTT на вільному порту і повертає його адресу.
+func startBroker(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := mqttserver.New(&mqttserver.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := server.AddHook(new(mqttauth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: address})); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return "tcp://" + address
+}
+
+// connectClient підключає тестовий клієнт, що грає роль пристрою.
+func connectClient(t *testing.T, broker, clientID string) mqtt.Client {
+	t.Helper()
+	client := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID(clientID))
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(250) })
+	return client
+}
+
+// waitForSubscriber публікує повідомлення з одним показником, доки сервер не відповість на нього,
+// і повертає ID збереженого показника. Повтори, опубліковані до відповіді, теж можуть отримати
+// відповіді — з тим самим ID і статусом duplicate.
+func waitForSubscriber(t *testing.T, client mqtt.Client, topic, payload string, acks chan ack) uint {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		client.Publish(topic, 1, false, payload).Wait()
+		select {
+		case a := <-acks:
+			if len(a.Results) != 1 || a.Results[0].Status != StatusAccepted {
+				t.Fatalf("unexpected ack: %+v", a)
+			}
+			return a.Results[0].ID
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	t.Fatal("subscriber did not respond")
+	return 0
+}
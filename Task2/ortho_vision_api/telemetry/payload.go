@@ -0,0 +1,103 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"ortho_vision_api/models"
+	"time"
+)
+
+// Версії формату показників, які надсилають смарт-окуляри.
+//
+// Версія 1 (schema_version відсутній або 1):
+//
+//	{"posture_angle": 30, "eye_strain": 250, "timestamp": "..."}
+//
+// де eye_strain — освітленість у lux.
+//
+// Версія 2:
+//
+//	{"schema_version": 2, "posture_angle": 30, "ambient_lux": 250,
+//	 "screen_distance_cm": 45, "blink_rate": 14, "battery_level": 80, "timestamp": "..."}
+//
+// де screen_distance_cm, blink_rate і battery_level необов'язкові.
+//
+// В обох версіях пристрій може додати "sequence" — монотонний номер показника на пристрої.
+// Показник із номером, який уже збережено, вважається повтором і не зберігається вдруге.
+// Прошивка, що після перепрошивки чи скидання починає нумерацію спочатку, має разом із
+// sequence надсилати "boot_id" — випадковий рядок, створений на початку нової нумерації.
+// Номери порівнюються лише в межах одного boot_id.
+const (
+	SchemaVersion1       = 1
+	SchemaVersion2       = 2
+	CurrentSchemaVersion = SchemaVersion2
+)
+
+// validReadingSQL — умова SQL для показників, що враховуються в статистиці, агрегатах і сповіщеннях.
+// Прошивка версії 1 надсилала нуль замість відсутнього нахилу чи освітленості, тому такі показники
+// пропускаються; у версії 2 нуль — звичайне виміряне значення. Має збігатися з isLegacyPlaceholder.
+const validReadingSQL = `NOT (schema_version = 1 AND (posture_angle = 0 OR ambient_lux = 0))`
+
+// isLegacyPlaceholder перевіряє, чи показник — заглушка прошивки версії 1 (див. validReadingSQL).
+func isLegacyPlaceholder(reading models.SmartGlassesData) bool {
+	return reading.SchemaVersion == SchemaVersion1 && (reading.PostureAngle == 0 || reading.AmbientLux == 0)
+}
+
+// Помилки розбору показника
+var (
+	ErrUnsupportedSchema = errors.New("unsupported schema_version")
+	ErrMissingPosture    = errors.New("posture_angle is required")
+	ErrMissingLux        = errors.New("ambient_lux is required")
+	ErrMissingEyeStrain  = errors.New("eye_strain is required for schema_version 1")
+)
+
+// payload — показник у будь-якій підтримуваній версії формату.
+type payload struct {
+	SchemaVersion  int       `json:"schema_version"`
+	BootID         string    `json:"boot_id"`
+	Sequence       *int64    `json:"sequence"`
+	Timestamp      time.Time `json:"timestamp"`
+	PostureAngle   *float64  `json:"posture_angle"`
+	AmbientLux     *float64  `json:"ambient_lux"`
+	EyeStrain      *float64  `json:"eye_strain"` // Версія 1: освітленість у lux
+	ScreenDistance *float64  `json:"screen_distance_cm"`
+	BlinkRate      *float64  `json:"blink_rate"`
+	BatteryLevel   *float64  `json:"battery_level"`
+}
+
+// DecodeReading розбирає показник будь-якої підтримуваної версії і приводить його до поточної моделі.
+// Перевірку значень виконує Validate.
+func DecodeReading(raw []byte) (models.SmartGlassesData, error) {
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return models.SmartGlassesData{}, ErrInvalidPayload
+	}
+
+	reading := models.SmartGlassesData{Timestamp: p.Timestamp, BootID: p.BootID, Sequence: p.Sequence}
+	switch p.SchemaVersion {
+	case 0, SchemaVersion1:
+		// Перші версії прошивки надсилали освітленість у полі eye_strain
+		if p.EyeStrain == nil {
+			return models.SmartGlassesData{}, ErrMissingEyeStrain
+		}
+		reading.SchemaVersion = SchemaVersion1
+		reading.AmbientLux = *p.EyeStrain
+	case SchemaVersion2:
+		if p.AmbientLux == nil {
+			return models.SmartGlassesData{}, ErrMissingLux
+		}
+		reading.SchemaVersion = SchemaVersion2
+		reading.AmbientLux = *p.AmbientLux
+		reading.ScreenDistance = p.ScreenDistance
+		reading.BlinkRate = p.BlinkRate
+		reading.BatteryLevel = p.BatteryLevel
+	default:
+		return models.SmartGlassesData{}, ErrUnsupportedSchema
+	}
+
+	if p.PostureAngle == nil {
+		return models.SmartGlassesData{}, ErrMissingPosture
+	}
+	reading.PostureAngle = *p.PostureAngle
+	return reading, nil
+}
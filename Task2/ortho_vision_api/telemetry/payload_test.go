@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"ortho_vision_api/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeReading(t *testing.T) {
+	timestamp := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	float := func(value float64) *float64 { return &value }
+	int64Ptr := func(value int64) *int64 { return &value }
+
+	tests := []struct {
+		name    string
+		payload string
+		want    models.SmartGlassesData
+		err     error
+	}{
+		{
+			name:    "version 1",
+			payload: `{"schema_version":1,"timestamp":"2026-01-05T09:00:00Z","posture_angle":12.5,"eye_strain":300}`,
+			want:    models.SmartGlassesData{SchemaVersion: SchemaVersion1, Timestamp: timestamp, PostureAngle: 12.5, AmbientLux: 300},
+		},
+		{
+			name:    "missing version is version 1",
+			payload: `{"timestamp":"2026-01-05T09:00:00Z","posture_angle":12.5,"eye_strain":300,"screen_distance_cm":40}`,
+			want:    models.SmartGlassesData{SchemaVersion: SchemaVersion1, Timestamp: timestamp, PostureAngle: 12.5, AmbientLux: 300},
+		},
+		{
+			name: "version 2",
+			payload: `{"schema_version":2,"boot_id":"a1b2","sequence":7,"timestamp":"2026-01-05T09:00:00Z",
+				"posture_angle":0,"ambient_lux":0,"screen_distance_cm":40,"blink_rate":15,"battery_level":80}`,
+			want: models.SmartGlassesData{
+				SchemaVersion: SchemaVersion2, BootID: "a1b2", Sequence: int64Ptr(7), Timestamp: timestamp,
+				ScreenDistance: float(40), BlinkRate: float(15), BatteryLevel: float(80),
+			},
+		},
+		{
+			name:    "version 1 without eye_strain",
+			payload: `{"schema_version":1,"posture_angle":12.5,"ambient_lux":300}`,
+			err:     ErrMissingEyeStrain,
+		},
+		{
+			name:    "version 2 without ambient_lux",
+			payload: `{"schema_version":2,"posture_angle":12.5,"eye_strain":300}`,
+			err:     ErrMissingLux,
+		},
+		{
+			name:    "missing posture_angle",
+			payload: `{"schema_version":2,"ambient_lux":300}`,
+			err:     ErrMissingPosture,
+		},
+		{
+			name:    "unsupported version",
+			payload: `{"schema_version":3,"posture_angle":12.5,"ambient_lux":300}`,
+			err:     ErrUnsupportedSchema,
+		},
+		{
+			name:    "not an object",
+			payload: `[1, 2]`,
+			err:     ErrInvalidPayload,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeReading([]byte(tt.payload))
+			if err != tt.err {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("reading = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsLegacyPlaceholder(t *testing.T) {
+	tests := []struct {
+		name    string
+		reading models.SmartGlassesData
+		want    bool
+	}{
+		{"version 1 zero posture", models.SmartGlassesData{SchemaVersion: SchemaVersion1, AmbientLux: 300}, true},
+		{"version 1 zero lux", models.SmartGlassesData{SchemaVersion: SchemaVersion1, PostureAngle: 10}, true},
+		{"version 1 measured", models.SmartGlassesData{SchemaVersion: SchemaVersion1, PostureAngle: 10, AmbientLux: 300}, false},
+		{"version 2 zeros are measured", models.SmartGlassesData{SchemaVersion: SchemaVersion2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLegacyPlaceholder(tt.reading); got != tt.want {
+				t.Fatalf("isLegacyPlaceholder = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package telemetry
+
+import (
+	"fmt"
+	"log"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rollupStateName — ключ запису зі станом агрегації.
+const rollupStateName = "rollup"
+
+// zeroReadingsRebuildName — ключ запису, що позначає разову перебудову агрегатів, з яких раніше
+// помилково виключалися нульові показники прошивки версії 2.
+const zeroReadingsRebuildName = "rebuild_v2_zero_readings"
+
+// minuteRollupSQL перебудовує похвилинні агрегати пацієнта за [from, to).
+// Тривалість порушень рахується так само, як у statsSQL; щоб LEAD бачила наступний показник
+// після останнього у вікні, вибірка захоплює ще max_gap після to.
+const minuteRollupSQL = `
+INSERT INTO %s (user_id, bucket_start, readings,
+	posture_angle_sum, posture_angle_min, posture_angle_max,
+	ambient_lux_sum, ambient_lux_min, ambient_lux_max,
+	time_head_tilt_exceeded, time_low_light, time_high_light)
+SELECT @patient_id, date_trunc('minute', "timestamp", 'UTC'), COUNT(*),
+	SUM(posture_angle), MIN(posture_angle), MAX(posture_angle),
+	SUM(ambient_lux), MIN(ambient_lux), MAX(ambient_lux),
+	COALESCE(SUM(seconds) FILTER (WHERE posture_angle > @max_posture_angle), 0),
+	COALESCE(SUM(seconds) FILTER (WHERE ambient_lux < @min_lux), 0),
+	COALESCE(SUM(seconds) FILTER (WHERE ambient_lux > @max_lux), 0)
+FROM (
+	SELECT "timestamp", posture_angle, ambient_lux,
+		COALESCE(LEAST(EXTRACT(EPOCH FROM LEAD("timestamp") OVER (ORDER BY "timestamp") - "timestamp"), @max_gap), 0) AS seconds
+	FROM %s
+	WHERE user_id = @patient_id AND "timestamp" >= @from AND "timestamp" < @lookahead
+		AND ` + validReadingSQL + `
+) readings
+WHERE "timestamp" < @to
+GROUP BY 2`
+
+// hourRollupSQL перебудовує погодинні агрегати пацієнта за [from, to) з похвилинних.
+const hourRollupSQL = `
+INSERT INTO %s (user_id, bucket_start, readings,
+	posture_angle_sum, posture_angle_min, posture_angle_max,
+	ambient_lux_sum, ambient_lux_min, ambient_lux_max,
+	time_head_tilt_exceeded, time_low_light, time_high_light)
+SELECT user_id, date_trunc('hour', bucket_start, 'UTC'), SUM(readings),
+	SUM(posture_angle_sum), MIN(posture_angle_min), MAX(posture_angle_max),
+	SUM(ambient_lux_sum), MIN(ambient_lux_min), MAX(ambient_lux_max),
+	SUM(time_head_tilt_exceeded), SUM(time_low_light), SUM(time_high_light)
+FROM %s
+WHERE user_id = @patient_id AND bucket_start >= @from AND bucket_start < @to
+GROUP BY 1, 2`
+
+// rollupRebuildSQL позначає для перебудови агрегати пацієнтів, що відповідають умові,
+// від найстарішого збереженого сирого показника кожного з них.
+const rollupRebuildSQL = `
+INSERT INTO %[1]s (user_id, pending_from)
+SELECT user_id, MIN("timestamp")
+FROM %[2]s
+WHERE %[3]s
+GROUP BY user_id
+ON CONFLICT (user_id) DO UPDATE SET pending_from = LEAST(%[1]s.pending_from, EXCLUDED.pending_from)`
+
+// RollupJob у фоні будує похвилинні й погодинні агрегати з сирих показників
+// і видаляє дані, старші за налаштовані терміни зберігання.
+type RollupJob struct {
+	db   *gorm.DB
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewRollupJob створює RollupJob. Щоб почати роботу, потрібно викликати Start.
+func NewRollupJob(db *gorm.DB) *RollupJob {
+	return &RollupJob{
+		db:   db,
+		stop: make(chan struct{}),
+	}
+}
+
+// Start запускає агрегацію й очищення з інтервалом config.RollupInterval.
+func (j *RollupJob) Start() {
+	j.done.Add(1)
+	go func() {
+		defer j.done.Done()
+		if err := j.backfill(); err != nil {
+			log.Println("Rollup backfill error:", err)
+		}
+		ticker := time.NewTicker(config.RollupInterval)
+		defer ticker.Stop()
+		for {
+			j.RunOnce()
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop зупиняє фонову роботу, дочекавшись завершення поточного запуску.
+func (j *RollupJob) Stop() {
+	close(j.stop)
+	j.done.Wait()
+}
+
+// backfill позначає для агрегації сирі показники пацієнтів, для яких агрегати ще жодного разу
+// не будувалися (наприклад, показники, збережені до появи агрегації).
+func (j *RollupJob) backfill() error {
+	if err := j.db.Exec(fmt.Sprintf(rollupRebuildSQL,
+		models.SmartGlassesRollupPending{}.TableName(),
+		models.SmartGlassesData{}.TableName(),
+		"user_id NOT IN (SELECT user_id FROM "+models.SmartGlassesRollupWatermark{}.TableName()+")")).Error; err != nil {
+		return err
+	}
+	return j.rebuildZeroReadings()
+}
+
+// rebuildZeroReadings один раз позначає для перебудови агрегати пацієнтів, що мають нульові
+// показники прошивки версії 2: раніше такі показники не враховувалися.
+func (j *RollupJob) rebuildZeroReadings() error {
+	return j.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.SmartGlassesRollupState{Name: zeroReadingsRebuildName, RolledUpTo: time.Now().UTC()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Перебудову вже виконано
+			return result.Error
+		}
+		return tx.Exec(fmt.Sprintf(rollupRebuildSQL,
+			models.SmartGlassesRollupPending{}.TableName(),
+			models.SmartGlassesData{}.TableName(),
+			"schema_version <> 1 AND (posture_angle = 0 OR ambient_lux = 0)")).Error
+	})
+}
+
+// RunOnce виконує один цикл агрегації та очищення.
+func (j *RollupJob) RunOnce() {
+	cutoff := time.Now().UTC().Add(-config.RollupLag).Truncate(time.Minute)
+	if err := j.rollup(cutoff); err != nil {
+		log.Println("Rollup error:", err)
+		return
+	}
+	if err := j.enforceRetention(cutoff); err != nil {
+		log.Println("Retention error:", err)
+	}
+}
+
+// rollup оновлює агрегати всіх пацієнтів, що мають неврахований показник, старіший за cutoff.
+func (j *RollupJob) rollup(cutoff time.Time) error {
+	var pending []models.SmartGlassesRollupPending
+	if err := j.db.Where("pending_from < ?", cutoff).Find(&pending).Error; err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if err := j.rollupPatient(p.UserID, cutoff); err != nil {
+			log.Println("Rollup error for patient", p.UserID, ":", err)
+		}
+	}
+
+	return j.db.Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&models.SmartGlassesRollupState{Name: rollupStateName, RolledUpTo: cutoff}).Error
+}
+
+// rollupPatient перебудовує агрегати одного пацієнта від найстарішого неврахованого показника до cutoff.
+func (j *RollupJob) rollupPatient(patientID uint, cutoff time.Time) error {
+	thresholds, err := ResolveThresholds(j.db, patientID)
+	if err == gorm.ErrRecordNotFound {
+		// Пацієнта видалено — агрегувати нічого
+		if err := j.db.Delete(&models.SmartGlassesRollupWatermark{}, patientID).Error; err != nil {
+			return err
+		}
+		return j.db.Delete(&models.SmartGlassesRollupPending{}, patientID).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	minuteTable := models.SmartGlassesMinuteRollup{}.TableName()
+	hourTable := models.SmartGlassesHourRollup{}.TableName()
+	rawTable := models.SmartGlassesData{}.TableName()
+
+	return j.db.Transaction(func(tx *gorm.DB) error {
+		// Блокуємо запис, щоб показники, збережені під час агрегації, знову позначили пацієнта
+		var pending models.SmartGlassesRollupPending
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pending, patientID).Error; err != nil {
+			return err
+		}
+
+		// Захоплюємо max_gap до першого показника: його попередник міг отримати новий наступний показник
+		from := pending.PendingFrom.UTC().Add(-config.StatsMaxGap).Truncate(time.Minute)
+
+		// Агрегати за період, сирі показники за який уже видалено, не перебудовуємо — інакше вони б зникли
+		var raw struct{ Earliest *time.Time }
+		if err := tx.Raw(`SELECT MIN("timestamp") AS earliest FROM `+rawTable+` WHERE user_id = ?`, patientID).Scan(&raw).Error; err != nil {
+			return err
+		}
+		if raw.Earliest == nil {
+			return tx.Delete(&pending).Error
+		}
+		if first := raw.Earliest.UTC().Truncate(time.Minute); from.Before(first) {
+			from = first
+		}
+		hourFrom := from.Truncate(time.Hour)
+		hourTo := cutoff.Truncate(time.Hour)
+
+		if err := tx.Exec("DELETE FROM "+minuteTable+" WHERE user_id = ? AND bucket_start >= ? AND bucket_start < ?",
+			patientID, from, cutoff).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf(minuteRollupSQL, minuteTable, rawTable), map[string]interface{}{
+			"patient_id":        patientID,
+			"from":              from,
+			"to":                cutoff,
+			"lookahead":         cutoff.Add(config.StatsMaxGap),
+			"max_gap":           config.StatsMaxGap.Seconds(),
+			"max_posture_angle": thresholds.MaxPostureAngle,
+			"min_lux":           thresholds.MinLux,
+			"max_lux":           thresholds.MaxLux,
+		}).Error; err != nil {
+			return err
+		}
+
+		if hourFrom.Before(hourTo) {
+			if err := tx.Exec("DELETE FROM "+hourTable+" WHERE user_id = ? AND bucket_start >= ? AND bucket_start < ?",
+				patientID, hourFrom, hourTo).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(fmt.Sprintf(hourRollupSQL, hourTable, minuteTable), map[string]interface{}{
+				"patient_id": patientID,
+				"from":       hourFrom,
+				"to":         hourTo,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		// Незавершена година буде перебудована наступного разу; якщо показників після неї немає — пацієнт готовий
+		var newer bool
+		if err := tx.Raw(`SELECT EXISTS (SELECT 1 FROM `+rawTable+` WHERE user_id = ? AND "timestamp" >= ?)`,
+			patientID, hourTo).Scan(&newer).Error; err != nil {
+			return err
+		}
+		next := cutoff
+		if newer {
+			next = hourTo
+			if err := tx.Model(&pending).Update("pending_from", hourTo).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Delete(&pending).Error; err != nil {
+			return err
+		}
+
+		// Наступна перебудова почнеться не раніше за next - max_gap, тож старіші сирі показники можна видаляти
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.SmartGlassesRollupWatermark{
+			UserID:     patientID,
+			RolledUpTo: next.Add(-config.StatsMaxGap).Truncate(time.Minute),
+		}).Error
+	})
+}
+
+// enforceRetention видаляє дані, старші за терміни зберігання.
+// Сирі показники пацієнта видаляються лише нижче його позначки агрегації і лише тоді,
+// коли вони не знадобляться для перебудови незавершених агрегатів.
+// Межі видалення вирівняні до хвилини, тож агрегат хвилини ніколи не перебудовується з частини її показників.
+func (j *RollupJob) enforceRetention(cutoff time.Time) error {
+	now := time.Now().UTC()
+
+	if config.RetentionRaw > 0 {
+		rawCutoff := now.Add(-config.RetentionRaw).Truncate(time.Minute)
+		if cutoff.Before(rawCutoff) {
+			rawCutoff = cutoff
+		}
+		err := j.db.Exec(`DELETE FROM `+models.SmartGlassesData{}.TableName()+` d
+			USING `+models.SmartGlassesRollupWatermark{}.TableName()+` w
+			WHERE w.user_id = d.user_id AND d."timestamp" < ? AND d."timestamp" < w.rolled_up_to
+				AND NOT EXISTS (
+					SELECT 1 FROM `+models.SmartGlassesRollupPending{}.TableName()+` p
+					WHERE p.user_id = d.user_id
+						AND d."timestamp" >= date_trunc('minute', p.pending_from - ? * INTERVAL '1 second', 'UTC')
+				)`, rawCutoff, config.StatsMaxGap.Seconds()).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.RetentionMinute > 0 {
+		if err := j.db.Where("bucket_start < ?", now.Add(-config.RetentionMinute)).
+			Delete(&models.SmartGlassesMinuteRollup{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if config.RetentionHour > 0 {
+		if err := j.db.Where("bucket_start < ?", now.Add(-config.RetentionHour)).
+			Delete(&models.SmartGlassesHourRollup{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidatePatientRollups позначає агрегати пацієнта для перебудови, наприклад після зміни
+// його порогових значень. Перебудовується весь період, за який ще є сирі показники;
+// до завершення перебудови статистика за цей період рахується із сирих показників.
+func InvalidatePatientRollups(db *gorm.DB, patientID uint) error {
+	return db.Exec(fmt.Sprintf(rollupRebuildSQL,
+		models.SmartGlassesRollupPending{}.TableName(),
+		models.SmartGlassesData{}.TableName(),
+		"user_id = ?"), patientID).Error
+}
+
+// InvalidateClinicRollups позначає для перебудови агрегати всіх пацієнтів клініки,
+// наприклад після зміни порогових значень клініки.
+func InvalidateClinicRollups(db *gorm.DB, clinicID uint) error {
+	return db.Exec(fmt.Sprintf(rollupRebuildSQL,
+		models.SmartGlassesRollupPending{}.TableName(),
+		models.SmartGlassesData{}.TableName(),
+		"user_id IN (?)"), db.Model(&models.User{}).Select("id").Where("clinic_id = ?", clinicID)).Error
+}
+
+// markRollupPending позначає, що показники пацієнта від часу from ще не враховано в агрегатах.
+func markRollupPending(tx *gorm.DB, patientID uint, from time.Time) error {
+	return tx.Exec(`INSERT INTO `+models.SmartGlassesRollupPending{}.TableName()+` (user_id, pending_from)
+		VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET pending_from = LEAST(`+models.SmartGlassesRollupPending{}.TableName()+`.pending_from, EXCLUDED.pending_from)`,
+		patientID, from).Error
+}
@@ -0,0 +1,288 @@
+package telemetry
+
+import (
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Періоди звіту про роботу на близькій відстані
+const (
+	ScreenTimePeriodDay  = "day"
+	ScreenTimePeriodWeek = "week"
+)
+
+// ScreenSession — безперервний сеанс роботи на близькій відстані.
+// Погляд удалину тривалістю від BreakMinDuration зараховується як перерва всередині сеансу;
+// якщо роботи на близькій відстані немає довше за SessionMaxPause, сеанс завершується.
+type ScreenSession struct {
+	Start                 time.Time `json:"start"`
+	End                   time.Time `json:"end"`
+	NearWorkSeconds       float64   `json:"near_work_seconds"`
+	Breaks                int       `json:"breaks"`
+	LongestStretchSeconds float64   `json:"longest_stretch_seconds"`
+	Stretches             int       `json:"stretches"`
+	CompliantStretches    int       `json:"compliant_stretches"`
+	MissedBreaks          int       `json:"missed_breaks"`
+}
+
+// ScreenTimeSummary — підсумок дотримання перерв за період.
+// Відрізок роботи — час між двома перервами; він вважається дотриманим, якщо не довший
+// за BreakInterval з допуском BreakGrace. MissedBreaks — скільки разів за відрізками
+// спливав BreakInterval без перерви.
+type ScreenTimeSummary struct {
+	NearWorkSeconds    float64 `json:"near_work_seconds"`
+	Sessions           int     `json:"sessions"`
+	Breaks             int     `json:"breaks"`
+	Stretches          int     `json:"stretches"`
+	CompliantStretches int     `json:"compliant_stretches"`
+	MissedBreaks       int     `json:"missed_breaks"`
+	ComplianceRate     float64 `json:"compliance_rate"` // Частка дотриманих відрізків, 0..1
+}
+
+// ScreenTimeDay — сеанси й підсумок за одну добу (сеанс належить добі, в яку він почався).
+type ScreenTimeDay struct {
+	Date     string            `json:"date"`
+	Summary  ScreenTimeSummary `json:"summary"`
+	Sessions []ScreenSession   `json:"sessions"`
+}
+
+// ScreenTimeReport — звіт про роботу на близькій відстані за добу або тиждень.
+type ScreenTimeReport struct {
+	Period   string            `json:"period"`
+	Timezone string            `json:"timezone"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Summary  ScreenTimeSummary `json:"summary"`
+	Days     []ScreenTimeDay   `json:"days"`
+}
+
+// BreakStatus — поточний стан роботи пацієнта для пристрою.
+type BreakStatus struct {
+	NearWork             bool       `json:"near_work"`
+	StretchStartedAt     *time.Time `json:"stretch_started_at,omitempty"`
+	StretchSeconds       float64    `json:"stretch_seconds"`
+	BreakDue             bool       `json:"break_due"`
+	BreakDueAt           *time.Time `json:"break_due_at,omitempty"`
+	BreakDurationSeconds float64    `json:"break_duration_seconds"`
+	BreakIntervalSeconds float64    `json:"break_interval_seconds"`
+}
+
+// IsNearWork перевіряє, чи показник відповідає роботі на близькій відстані.
+// Показники без відстані до екрана (схема версії 1) роботою не вважаються.
+func IsNearWork(reading models.SmartGlassesData) bool {
+	return reading.ScreenDistance != nil && *reading.ScreenDistance > 0 && *reading.ScreenDistance <= config.NearWorkMaxDistance
+}
+
+// sessionTracker послідовно розбиває показники на сеанси та відрізки роботи.
+// Перерва визначається лише за показниками дальньої відстані: проміжок без показників
+// означає відсутність даних, а не перерву.
+type sessionTracker struct {
+	sessions     []ScreenSession
+	current      *ScreenSession
+	stretchStart time.Time
+	lastNear     time.Time
+	farStart     time.Time // Перший показник дальньої відстані після lastNear; нульовий, якщо їх не було
+	lastFar      time.Time // Останній такий показник
+}
+
+// add обробляє наступний показник; показники мають надходити в порядку часу.
+func (t *sessionTracker) add(timestamp time.Time, nearWork bool) {
+	if t.current == nil {
+		if nearWork {
+			t.startSession(timestamp)
+		}
+		return
+	}
+	if !nearWork {
+		if t.farStart.IsZero() {
+			t.farStart = timestamp
+		}
+		t.lastFar = timestamp
+		return
+	}
+
+	switch {
+	case timestamp.Sub(t.lastNear) > config.SessionMaxPause:
+		t.closeSession()
+		t.startSession(timestamp)
+		return
+	case t.onBreak():
+		t.closeStretch()
+		t.current.Breaks++
+		t.stretchStart = timestamp
+	}
+	t.lastNear = timestamp
+	t.farStart, t.lastFar = time.Time{}, time.Time{}
+}
+
+// onBreak перевіряє, чи показники дальньої відстані після останньої роботи
+// тривають щонайменше BreakMinDuration.
+func (t *sessionTracker) onBreak() bool {
+	return !t.farStart.IsZero() && t.lastFar.Sub(t.farStart) >= config.BreakMinDuration
+}
+
+// startSession починає новий сеанс і перший відрізок роботи в ньому.
+func (t *sessionTracker) startSession(timestamp time.Time) {
+	t.current = &ScreenSession{Start: timestamp}
+	t.stretchStart = timestamp
+	t.lastNear = timestamp
+	t.farStart, t.lastFar = time.Time{}, time.Time{}
+}
+
+// closeStretch завершує відрізок роботи на останньому показнику роботи.
+func (t *sessionTracker) closeStretch() {
+	length := t.lastNear.Sub(t.stretchStart)
+	t.current.NearWorkSeconds += length.Seconds()
+	if length.Seconds() > t.current.LongestStretchSeconds {
+		t.current.LongestStretchSeconds = length.Seconds()
+	}
+	t.current.Stretches++
+	if length <= config.BreakInterval+config.BreakGrace {
+		t.current.CompliantStretches++
+	}
+	t.current.MissedBreaks += int(length / config.BreakInterval)
+}
+
+// closeSession завершує поточний сеанс разом з останнім відрізком роботи.
+func (t *sessionTracker) closeSession() {
+	t.closeStretch()
+	t.current.End = t.lastNear
+	t.sessions = append(t.sessions, *t.current)
+	t.current = nil
+}
+
+// finish завершує відкритий сеанс.
+func (t *sessionTracker) finish() {
+	if t.current != nil {
+		t.closeSession()
+	}
+}
+
+// loadNearWork передає трекеру показники пацієнта за [from, to) у порядку часу.
+// Показники без відстані до екрана (схема версії 1) пропускаються: для обліку перерв це відсутні дані.
+func loadNearWork(db *gorm.DB, patientID uint, from, to time.Time, tracker *sessionTracker) error {
+	rows, err := db.Model(&models.SmartGlassesData{}).
+		Select("timestamp", "screen_distance").
+		Where(`user_id = ? AND "timestamp" >= ? AND "timestamp" < ? AND screen_distance > 0`, patientID, from, to).
+		Order(`"timestamp"`).
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reading models.SmartGlassesData
+		if err := rows.Scan(&reading.Timestamp, &reading.ScreenDistance); err != nil {
+			return err
+		}
+		tracker.add(reading.Timestamp, IsNearWork(reading))
+	}
+	return rows.Err()
+}
+
+// ComputeScreenTime будує звіт за добу (починаючи з day) або за тиждень (з понеділка тижня, що містить day).
+// Межі діб визначаються в часовому поясі day.
+func ComputeScreenTime(db *gorm.DB, patientID uint, period string, day time.Time) (*ScreenTimeReport, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	days := 1
+	if period == ScreenTimePeriodWeek {
+		from = weekStartOf(day)
+		days = 7
+	}
+	to := from.AddDate(0, 0, days)
+
+	var tracker sessionTracker
+	if err := loadNearWork(db, patientID, from, to, &tracker); err != nil {
+		return nil, err
+	}
+
+	report := &ScreenTimeReport{
+		Period:   period,
+		Timezone: from.Location().String(),
+		From:     from,
+		To:       to,
+		Days:     make([]ScreenTimeDay, days),
+	}
+	for i := range report.Days {
+		report.Days[i] = ScreenTimeDay{
+			Date:     from.AddDate(0, 0, i).Format("2006-01-02"),
+			Sessions: []ScreenSession{},
+		}
+	}
+
+	tracker.finish()
+	for _, session := range tracker.sessions {
+		for i := range report.Days {
+			if session.Start.Before(from.AddDate(0, 0, i+1)) {
+				report.Days[i].Sessions = append(report.Days[i].Sessions, session)
+				report.Days[i].Summary.addSession(session)
+				break
+			}
+		}
+	}
+	for i := range report.Days {
+		report.Days[i].Summary.updateRate()
+		report.Summary.add(report.Days[i].Summary)
+	}
+	report.Summary.updateRate()
+	return report, nil
+}
+
+// addSession додає сеанс до підсумку.
+func (s *ScreenTimeSummary) addSession(session ScreenSession) {
+	s.NearWorkSeconds += session.NearWorkSeconds
+	s.Sessions++
+	s.Breaks += session.Breaks
+	s.Stretches += session.Stretches
+	s.CompliantStretches += session.CompliantStretches
+	s.MissedBreaks += session.MissedBreaks
+}
+
+// add додає підсумок іншого періоду.
+func (s *ScreenTimeSummary) add(other ScreenTimeSummary) {
+	s.NearWorkSeconds += other.NearWorkSeconds
+	s.Sessions += other.Sessions
+	s.Breaks += other.Breaks
+	s.Stretches += other.Stretches
+	s.CompliantStretches += other.CompliantStretches
+	s.MissedBreaks += other.MissedBreaks
+}
+
+// updateRate перераховує частку дотриманих відрізків; без роботи на близькій відстані вона дорівнює 1.
+func (s *ScreenTimeSummary) updateRate() {
+	if s.Stretches == 0 {
+		s.ComplianceRate = 1
+		return
+	}
+	s.ComplianceRate = float64(s.CompliantStretches) / float64(s.Stretches)
+}
+
+// CurrentBreakStatus визначає, чи пацієнт зараз працює на близькій відстані і чи настав час перерви.
+func CurrentBreakStatus(db *gorm.DB, patientID uint, now time.Time) (BreakStatus, error) {
+	status := BreakStatus{
+		BreakDurationSeconds: config.BreakMinDuration.Seconds(),
+		BreakIntervalSeconds: config.BreakInterval.Seconds(),
+	}
+
+	var tracker sessionTracker
+	if err := loadNearWork(db, patientID, now.Add(-config.BreakStatusWindow), now.Add(config.TelemetryMaxClockSkew), &tracker); err != nil {
+		return status, err
+	}
+	// Робота триває, поки пацієнт не зробив перерву і сеанс не завершився
+	if tracker.current == nil || now.Sub(tracker.lastNear) > config.SessionMaxPause || tracker.onBreak() {
+		return status, nil
+	}
+
+	stretchStart := tracker.stretchStart
+	dueAt := stretchStart.Add(config.BreakInterval)
+	status.NearWork = true
+	status.StretchStartedAt = &stretchStart
+	status.StretchSeconds = now.Sub(stretchStart).Seconds()
+	status.BreakDueAt = &dueAt
+	status.BreakDue = !now.Before(dueAt)
+	return status, nil
+}
@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionTracker(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	type reading struct {
+		offset   time.Duration
+		nearWork bool
+	}
+	tests := []struct {
+		name     string
+		readings []reading
+		want     []ScreenSession
+	}{
+		{
+			name:     "far readings only",
+			readings: []reading{{0, false}, {time.Minute, false}},
+			want:     nil,
+		},
+		{
+			name:     "short stretch",
+			readings: []reading{{0, true}, {5 * time.Minute, true}, {10 * time.Minute, true}},
+			want: []ScreenSession{{
+				Start: at(0), End: at(10 * time.Minute), NearWorkSeconds: 600,
+				LongestStretchSeconds: 600, Stretches: 1, CompliantStretches: 1,
+			}},
+		},
+		{
+			name: "stretch longer than break interval",
+			readings: []reading{
+				{0, true}, {5 * time.Minute, true}, {10 * time.Minute, true},
+				{15 * time.Minute, true}, {20 * time.Minute, true}, {25 * time.Minute, true},
+			},
+			want: []ScreenSession{{
+				Start: at(0), End: at(25 * time.Minute), NearWorkSeconds: 1500,
+				LongestStretchSeconds: 1500, Stretches: 1, MissedBreaks: 1,
+			}},
+		},
+		{
+			name: "break splits stretches",
+			readings: []reading{
+				{0, true}, {5 * time.Minute, true}, {10 * time.Minute, true},
+				{10*time.Minute + 10*time.Second, false}, {10*time.Minute + 30*time.Second, false},
+				{11 * time.Minute, true}, {15 * time.Minute, true}, {20 * time.Minute, true},
+			},
+			want: []ScreenSession{{
+				Start: at(0), End: at(20 * time.Minute), NearWorkSeconds: 1140, Breaks: 1,
+				LongestStretchSeconds: 600, Stretches: 2, CompliantStretches: 2,
+			}},
+		},
+		{
+			name: "short glance is not a break",
+			readings: []reading{
+				{0, true}, {5 * time.Minute, true}, {10 * time.Minute, true},
+				{10*time.Minute + 5*time.Second, false}, {10*time.Minute + 15*time.Second, false},
+				{10*time.Minute + 30*time.Second, true},
+			},
+			want: []ScreenSession{{
+				Start: at(0), End: at(10*time.Minute + 30*time.Second), NearWorkSeconds: 630,
+				LongestStretchSeconds: 630, Stretches: 1, CompliantStretches: 1,
+			}},
+		},
+		{
+			name: "long pause starts new session",
+			readings: []reading{
+				{0, true}, {2 * time.Minute, true},
+				{10 * time.Minute, true}, {12 * time.Minute, true},
+			},
+			want: []ScreenSession{
+				{
+					Start: at(0), End: at(2 * time.Minute), NearWorkSeconds: 120,
+					LongestStretchSeconds: 120, Stretches: 1, CompliantStretches: 1,
+				},
+				{
+					Start: at(10 * time.Minute), End: at(12 * time.Minute), NearWorkSeconds: 120,
+					LongestStretchSeconds: 120, Stretches: 1, CompliantStretches: 1,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker sessionTracker
+			for _, r := range tt.readings {
+				tracker.add(at(r.offset), r.nearWork)
+			}
+			tracker.finish()
+			if !reflect.DeepEqual(tracker.sessions, tt.want) {
+				t.Fatalf("sessions = %+v, want %+v", tracker.sessions, tt.want)
+			}
+		})
+	}
+}
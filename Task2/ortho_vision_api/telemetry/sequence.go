@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"fmt"
+	"ortho_vision_api/models"
+
+	"gorm.io/gorm"
+)
+
+// SequenceGap — діапазон номерів послідовності [Start, End], яких сервер не отримав.
+type SequenceGap struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Count int64 `json:"count"`
+}
+
+// SequenceReport — отримані пристроєм номери послідовності та пропуски між ними.
+type SequenceReport struct {
+	FirstSequence *int64        `json:"first_sequence"`
+	LastSequence  *int64        `json:"last_sequence"`
+	Received      int64         `json:"received"`
+	Missing       int64         `json:"missing"`
+	Gaps          []SequenceGap `json:"gaps"`
+	Truncated     bool          `json:"truncated"` // Пропусків більше, ніж повернуто
+}
+
+// sequenceGapsSQL знаходить пропуски між сусідніми збереженими номерами послідовності пристрою.
+const sequenceGapsSQL = `
+SELECT sequence + 1 AS start, next_sequence - 1 AS "end", next_sequence - sequence - 1 AS count
+FROM (
+	SELECT sequence, LEAD(sequence) OVER (ORDER BY sequence) AS next_sequence
+	FROM %s
+	WHERE device_id = @device_id AND boot_id = @boot_id AND sequence BETWEEN @from AND @to
+) s
+WHERE next_sequence > sequence + 1
+ORDER BY start
+LIMIT @limit`
+
+// FindSequenceGaps повертає пропуски в номерах послідовності пристрою з boot_id bootID в межах [from, to].
+// Пропуски шукаються лише між отриманими номерами: показники до першого й після останнього
+// отриманого номера пропусками не вважаються. Повертається не більше limit пропусків.
+func FindSequenceGaps(db *gorm.DB, deviceID uint, bootID string, from, to int64, limit int) (*SequenceReport, error) {
+	table := models.SmartGlassesData{}.TableName()
+
+	var summary struct {
+		First    *int64
+		Last     *int64
+		Received int64
+	}
+	if err := db.Table(table).
+		Select("MIN(sequence) AS first, MAX(sequence) AS last, COUNT(*) AS received").
+		Where("device_id = ? AND boot_id = ? AND sequence BETWEEN ? AND ?", deviceID, bootID, from, to).
+		Scan(&summary).Error; err != nil {
+		return nil, err
+	}
+
+	report := &SequenceReport{
+		FirstSequence: summary.First,
+		LastSequence:  summary.Last,
+		Received:      summary.Received,
+		Gaps:          []SequenceGap{},
+	}
+	if summary.Received == 0 {
+		return report, nil
+	}
+	report.Missing = *summary.Last - *summary.First + 1 - summary.Received
+
+	// Беремо на один пропуск більше, щоб знати, чи список обрізано
+	err := db.Raw(fmt.Sprintf(sequenceGapsSQL, table), map[string]interface{}{
+		"device_id": deviceID,
+		"boot_id":   bootID,
+		"from":      from,
+		"to":        to,
+		"limit":     limit + 1,
+	}).Scan(&report.Gaps).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Gaps) > limit {
+		report.Gaps = report.Gaps[:limit]
+		report.Truncated = true
+	}
+	return report, nil
+}
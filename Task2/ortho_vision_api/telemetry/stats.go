@@ -0,0 +1,316 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Інтервали групування статистики
+const (
+	BucketHour  = "hour"
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// Помилки параметрів статистики
+var (
+	ErrInvalidBucket = errors.New("bucket must be one of: hour, day, week, month")
+	ErrInvalidRange  = errors.New("from must be earlier than to")
+	ErrRangeTooLarge = errors.New("requested period is too long")
+)
+
+// StatsRow — тривалість порушень (у секундах) за період або за один інтервал групування.
+type StatsRow struct {
+	BucketStart          *time.Time `json:"bucket_start,omitempty"`
+	TimeHeadTiltExceeded float64    `json:"time_head_tilt_exceeded"`
+	TimeLowLight         float64    `json:"time_low_light"`
+	TimeHighLight        float64    `json:"time_high_light"`
+	Readings             int64      `json:"readings"`
+}
+
+// StatsQuery — параметри запиту статистики.
+type StatsQuery struct {
+	PatientID  uint
+	From       time.Time // Початок періоду (включно)
+	To         time.Time // Кінець періоду (не включно)
+	Bucket     string    // Порожній — без групування
+	Thresholds Thresholds
+	Location   *time.Location // Часовий пояс, за яким вирівнюються доби, тижні й місяці; nil — UTC
+}
+
+// timezone повертає назву часового поясу для date_trunc.
+func (q StatsQuery) timezone() string {
+	if q.Location == nil {
+		return "UTC"
+	}
+	return q.Location.String()
+}
+
+// Validate перевіряє період та інтервал групування.
+func (q StatsQuery) Validate() error {
+	if !q.From.Before(q.To) {
+		return ErrInvalidRange
+	}
+	if q.To.Sub(q.From) > config.StatsMaxRange {
+		return ErrRangeTooLarge
+	}
+	switch q.Bucket {
+	case "", BucketHour, BucketDay, BucketWeek, BucketMonth:
+		return nil
+	}
+	return ErrInvalidBucket
+}
+
+// statsSQL рахує тривалість порушень у Postgres. Для кожного показника віконна функція LEAD
+// знаходить час наступного показника; якщо показник порушує межу, проміжок до наступного
+// зараховується до тривалості порушення (не більше за max_gap, щоб перерви в носінні окулярів
+// не рахувалися). Заглушки старої прошивки (див. validReadingSQL) пропускаються.
+const statsSQL = `
+WITH readings AS (
+	SELECT "timestamp", posture_angle, ambient_lux,
+		LEAD("timestamp") OVER (ORDER BY "timestamp") AS next_timestamp
+	FROM %s
+	WHERE user_id = @patient_id AND "timestamp" >= @from AND "timestamp" < @to
+		AND ` + validReadingSQL + `
+), intervals AS (
+	SELECT "timestamp", posture_angle, ambient_lux,
+		COALESCE(LEAST(EXTRACT(EPOCH FROM next_timestamp - "timestamp"), @max_gap), 0) AS seconds
+	FROM readings
+)
+SELECT %s AS bucket_start,
+	COALESCE(SUM(seconds) FILTER (WHERE posture_angle > @max_posture_angle), 0) AS time_head_tilt_exceeded,
+	COALESCE(SUM(seconds) FILTER (WHERE ambient_lux < @min_lux), 0) AS time_low_light,
+	COALESCE(SUM(seconds) FILTER (WHERE ambient_lux > @max_lux), 0) AS time_high_light,
+	COUNT(*) AS readings
+FROM intervals
+%s`
+
+// rollupStatsSQL підсумовує вже пораховані агрегати (похвилинні або погодинні).
+const rollupStatsSQL = `
+SELECT %s AS bucket_start,
+	COALESCE(SUM(time_head_tilt_exceeded), 0) AS time_head_tilt_exceeded,
+	COALESCE(SUM(time_low_light), 0) AS time_low_light,
+	COALESCE(SUM(time_high_light), 0) AS time_high_light,
+	COALESCE(SUM(readings), 0) AS readings
+FROM %s
+WHERE user_id = @patient_id AND bucket_start >= @from AND bucket_start < @to
+%s`
+
+// Джерела даних для статистики
+const (
+	SourceRaw    = "raw"
+	SourceMinute = "minute"
+	SourceHour   = "hour"
+)
+
+// ComputeStats повертає тривалість порушень за період: один рядок без групування
+// або по рядку на кожен інтервал, у якому є показники, а також використане джерело даних.
+// Дані беруться з найгрубшої таблиці агрегатів, що відповідає періоду; частина періоду,
+// ще не врахована в агрегатах, рахується із сирих показників.
+func ComputeStats(db *gorm.DB, q StatsQuery) ([]StatsRow, string, error) {
+	if err := q.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	source, granularity := statsSource(q, time.Now())
+	if source == SourceRaw {
+		rows, err := rawStats(db, q, q.From, q.To)
+		return q.localize(rows), source, err
+	}
+
+	validUntil, err := rollupValidUntil(db, q.PatientID)
+	if err != nil {
+		return nil, "", err
+	}
+	split := validUntil.Truncate(granularity)
+	if split.Before(q.From) {
+		split = q.From
+	}
+	if split.After(q.To) {
+		split = q.To
+	}
+
+	table := models.SmartGlassesHourRollup{}.TableName()
+	if source == SourceMinute {
+		table = models.SmartGlassesMinuteRollup{}.TableName()
+	}
+	rows, err := rollupStats(db, table, q, q.From, split)
+	if err != nil {
+		return nil, "", err
+	}
+	if split.Before(q.To) {
+		recent, err := rawStats(db, q, split, q.To)
+		if err != nil {
+			return nil, "", err
+		}
+		rows = mergeStats(rows, recent, q.Bucket)
+	}
+	return q.localize(rows), source, nil
+}
+
+// statsSource обирає найгрубшу таблицю, межі якої збігаються з межами періоду.
+// Якщо сирі показники за початок періоду вже видалено, використовуються агрегати навіть
+// для невирівняного періоду — межі тоді округлюються до хвилини або години.
+func statsSource(q StatsQuery, now time.Time) (string, time.Duration) {
+	aligned := func(d time.Duration) bool {
+		return q.From.Equal(q.From.Truncate(d)) && q.To.Equal(q.To.Truncate(d))
+	}
+	expired := func(retention time.Duration) bool {
+		return retention > 0 && q.From.Before(now.Add(-retention))
+	}
+	// Погодинні агрегати вирівняні за UTC, тому інтервали групування в поясі
+	// зі зміщенням не на цілу годину (наприклад, +05:30) з них не скласти
+	wholeHourZone := func() bool {
+		if q.Bucket == "" || q.Location == nil {
+			return true
+		}
+		_, fromOffset := q.From.In(q.Location).Zone()
+		_, toOffset := q.To.In(q.Location).Zone()
+		return fromOffset%3600 == 0 && toOffset%3600 == 0
+	}
+
+	switch {
+	case aligned(time.Hour) && wholeHourZone(), expired(config.RetentionMinute):
+		return SourceHour, time.Hour
+	case aligned(time.Minute), expired(config.RetentionRaw):
+		return SourceMinute, time.Minute
+	}
+	return SourceRaw, 0
+}
+
+// rollupValidUntil повертає час, до якого агрегати пацієнта повні.
+// Агрегати, позначені для перебудови (нові показники або зміна порогових значень), не вважаються повними.
+func rollupValidUntil(db *gorm.DB, patientID uint) (time.Time, error) {
+	var state models.SmartGlassesRollupState
+	result := db.Where("name = ?", rollupStateName).Limit(1).Find(&state)
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, nil
+	}
+
+	var pending models.SmartGlassesRollupPending
+	result = db.Where("user_id = ?", patientID).Limit(1).Find(&pending)
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected > 0 && pending.PendingFrom.Before(state.RolledUpTo) {
+		return pending.PendingFrom, nil
+	}
+	return state.RolledUpTo, nil
+}
+
+// rawStats рахує статистику за [from, to) із сирих показників.
+func rawStats(db *gorm.DB, q StatsQuery, from, to time.Time) ([]StatsRow, error) {
+	bucketExpr, groupBy := statsGrouping(q.Bucket, `"timestamp"`)
+
+	var rows []StatsRow
+	err := db.Raw(fmt.Sprintf(statsSQL, models.SmartGlassesData{}.TableName(), bucketExpr, groupBy), map[string]interface{}{
+		"patient_id":        q.PatientID,
+		"from":              from,
+		"to":                to,
+		"bucket":            q.Bucket,
+		"timezone":          q.timezone(),
+		"max_gap":           config.StatsMaxGap.Seconds(),
+		"max_posture_angle": q.Thresholds.MaxPostureAngle,
+		"min_lux":           q.Thresholds.MinLux,
+		"max_lux":           q.Thresholds.MaxLux,
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// rollupStats підсумовує агрегати з таблиці table за [from, to).
+func rollupStats(db *gorm.DB, table string, q StatsQuery, from, to time.Time) ([]StatsRow, error) {
+	if !from.Before(to) {
+		return nil, nil
+	}
+	bucketExpr, groupBy := statsGrouping(q.Bucket, "bucket_start")
+
+	var rows []StatsRow
+	err := db.Raw(fmt.Sprintf(rollupStatsSQL, bucketExpr, table, groupBy), map[string]interface{}{
+		"patient_id": q.PatientID,
+		"from":       from,
+		"to":         to,
+		"bucket":     q.Bucket,
+		"timezone":   q.timezone(),
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// localize переводить початки інтервалів у часовий пояс запиту.
+func (q StatsQuery) localize(rows []StatsRow) []StatsRow {
+	if q.Location == nil {
+		return rows
+	}
+	for i := range rows {
+		if rows[i].BucketStart != nil {
+			local := rows[i].BucketStart.In(q.Location)
+			rows[i].BucketStart = &local
+		}
+	}
+	return rows
+}
+
+// statsGrouping повертає вираз початку інтервалу і GROUP BY для вказаного групування.
+// Інтервали вирівнюються за місцевим часом параметра @timezone з урахуванням переходу на літній час.
+func statsGrouping(bucket, column string) (string, string) {
+	if bucket == "" {
+		return "NULL::timestamptz", ""
+	}
+	return "date_trunc(@bucket, " + column + ", @timezone)", "GROUP BY 1 ORDER BY 1"
+}
+
+// mergeStats об'єднує статистику з агрегатів і сирих показників.
+func mergeStats(a, b []StatsRow, bucket string) []StatsRow {
+	if bucket == "" {
+		return []StatsRow{Total(append(a, b...))}
+	}
+
+	merged := make([]StatsRow, 0, len(a)+len(b))
+	index := make(map[int64]int)
+	for _, row := range append(a, b...) {
+		if row.BucketStart == nil {
+			continue
+		}
+		key := row.BucketStart.Unix()
+		if i, ok := index[key]; ok {
+			merged[i].TimeHeadTiltExceeded += row.TimeHeadTiltExceeded
+			merged[i].TimeLowLight += row.TimeLowLight
+			merged[i].TimeHighLight += row.TimeHighLight
+			merged[i].Readings += row.Readings
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, row)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].BucketStart.Before(*merged[j].BucketStart)
+	})
+	return merged
+}
+
+// Total підсумовує рядки статистики в один.
+func Total(rows []StatsRow) StatsRow {
+	var total StatsRow
+	for _, row := range rows {
+		total.TimeHeadTiltExceeded += row.TimeHeadTiltExceeded
+		total.TimeLowLight += row.TimeLowLight
+		total.TimeHighLight += row.TimeHighLight
+		total.Readings += row.Readings
+	}
+	return total
+}
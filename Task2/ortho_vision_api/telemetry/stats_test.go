@@ -0,0 +1,117 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsSource(t *testing.T) {
+	now := time.Date(2026, 6, 15, 12, 34, 56, 0, time.UTC)
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		q           StatsQuery
+		source      string
+		granularity time.Duration
+	}{
+		{"hour aligned", StatsQuery{From: hour, To: hour.Add(24 * time.Hour)}, SourceHour, time.Hour},
+		{"minute aligned", StatsQuery{From: hour.Add(time.Minute), To: hour.Add(time.Hour)}, SourceMinute, time.Minute},
+		{"unaligned", StatsQuery{From: hour.Add(time.Second), To: hour.Add(time.Hour)}, SourceRaw, 0},
+		{
+			"unaligned after raw retention",
+			StatsQuery{From: now.AddDate(0, 0, -100).Add(time.Second), To: now},
+			SourceMinute, time.Minute,
+		},
+		{
+			"unaligned after minute retention",
+			StatsQuery{From: now.AddDate(0, 0, -400).Add(time.Second), To: now},
+			SourceHour, time.Hour,
+		},
+		{
+			"whole hour zone",
+			StatsQuery{From: hour, To: hour.Add(24 * time.Hour), Bucket: BucketDay, Location: kyiv},
+			SourceHour, time.Hour,
+		},
+		{
+			"half hour zone",
+			StatsQuery{From: hour, To: hour.Add(24 * time.Hour), Bucket: BucketDay, Location: kolkata},
+			SourceMinute, time.Minute,
+		},
+		{
+			"half hour zone without grouping",
+			StatsQuery{From: hour, To: hour.Add(24 * time.Hour), Location: kolkata},
+			SourceHour, time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, granularity := statsSource(tt.q, now)
+			if source != tt.source || granularity != tt.granularity {
+				t.Fatalf("statsSource = %s, %v, want %s, %v", source, granularity, tt.source, tt.granularity)
+			}
+		})
+	}
+}
+
+func TestMergeStats(t *testing.T) {
+	bucket := func(hour int) *time.Time {
+		start := time.Date(2026, 1, 5, hour, 0, 0, 0, time.UTC)
+		return &start
+	}
+
+	tests := []struct {
+		name   string
+		a, b   []StatsRow
+		bucket string
+		want   []StatsRow
+	}{
+		{
+			name:   "without grouping",
+			a:      []StatsRow{{TimeHeadTiltExceeded: 10, TimeLowLight: 1, Readings: 5}},
+			b:      []StatsRow{{TimeHeadTiltExceeded: 5, TimeHighLight: 2, Readings: 3}},
+			bucket: "",
+			want:   []StatsRow{{TimeHeadTiltExceeded: 15, TimeLowLight: 1, TimeHighLight: 2, Readings: 8}},
+		},
+		{
+			name: "shared bucket is summed",
+			a: []StatsRow{
+				{BucketStart: bucket(9), TimeHeadTiltExceeded: 10, Readings: 5},
+				{BucketStart: bucket(10), TimeLowLight: 20, Readings: 6},
+			},
+			b: []StatsRow{
+				{BucketStart: bucket(11), TimeHighLight: 3, Readings: 1},
+				{BucketStart: bucket(10), TimeLowLight: 5, TimeHighLight: 1, Readings: 2},
+			},
+			bucket: BucketHour,
+			want: []StatsRow{
+				{BucketStart: bucket(9), TimeHeadTiltExceeded: 10, Readings: 5},
+				{BucketStart: bucket(10), TimeLowLight: 25, TimeHighLight: 1, Readings: 8},
+				{BucketStart: bucket(11), TimeHighLight: 3, Readings: 1},
+			},
+		},
+		{
+			name:   "rows without bucket are skipped",
+			a:      []StatsRow{{TimeHeadTiltExceeded: 10}},
+			b:      []StatsRow{{BucketStart: bucket(9), Readings: 1}},
+			bucket: BucketHour,
+			want:   []StatsRow{{BucketStart: bucket(9), Readings: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeStats(tt.a, tt.b, tt.bucket); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("mergeStats = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
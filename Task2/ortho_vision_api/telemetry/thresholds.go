@@ -0,0 +1,157 @@
+package telemetry
+
+import (
+	"errors"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+
+	"gorm.io/gorm"
+)
+
+// Помилки перевірки профілю порогових значень
+var (
+	ErrInvalidPostureThreshold = errors.New("max_posture_angle must be between 0 and 180")
+	ErrInvalidLuxThreshold     = errors.New("min_lux and max_lux must be between 0 and 200000")
+	ErrLuxRange                = errors.New("min_lux must be less than max_lux")
+	ErrResolvedLuxRange        = errors.New("min_lux must be less than max_lux once clinic and patient thresholds are combined")
+)
+
+// Thresholds — межі, що застосовуються до показників конкретного пацієнта.
+type Thresholds struct {
+	MaxPostureAngle float64 `json:"max_posture_angle"`
+	MinLux          float64 `json:"min_lux"`
+	MaxLux          float64 `json:"max_lux"`
+}
+
+// DefaultThresholds повертає загальні межі з налаштувань сервера.
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		MaxPostureAngle: config.DefaultMaxPostureAngle,
+		MinLux:          config.DefaultMinLux,
+		MaxLux:          config.DefaultMaxLux,
+	}
+}
+
+// PostureExceeded перевіряє, чи перевищує нахил голови допустиму межу.
+func (t Thresholds) PostureExceeded(angle float64) bool {
+	return angle > t.MaxPostureAngle
+}
+
+// LowLight перевіряє, чи освітленість нижча за допустиму.
+func (t Thresholds) LowLight(lux float64) bool {
+	return lux < t.MinLux
+}
+
+// HighLight перевіряє, чи освітленість вища за допустиму.
+func (t Thresholds) HighLight(lux float64) bool {
+	return lux > t.MaxLux
+}
+
+// apply замінює значення, задані в профілі; порожні поля профілю не змінюють межі.
+func (t *Thresholds) apply(profile models.ThresholdProfile) {
+	if profile.MaxPostureAngle != nil {
+		t.MaxPostureAngle = *profile.MaxPostureAngle
+	}
+	if profile.MinLux != nil {
+		t.MinLux = *profile.MinLux
+	}
+	if profile.MaxLux != nil {
+		t.MaxLux = *profile.MaxLux
+	}
+}
+
+// ResolveThresholds повертає межі для пацієнта: загальні налаштування,
+// поверх них — профіль клініки пацієнта, поверх нього — індивідуальний профіль пацієнта.
+func ResolveThresholds(db *gorm.DB, patientID uint) (Thresholds, error) {
+	var patient models.User
+	if err := db.Select("id", "clinic_id").First(&patient, patientID).Error; err != nil {
+		return Thresholds{}, err
+	}
+	return resolve(db, patient.ClinicID, &patient.ID)
+}
+
+// ResolveClinicThresholds повертає межі, що діють для пацієнтів клініки без індивідуального профілю.
+func ResolveClinicThresholds(db *gorm.DB, clinicID uint) (Thresholds, error) {
+	return resolve(db, &clinicID, nil)
+}
+
+// resolve послідовно застосовує профіль клініки й профіль пацієнта до загальних меж.
+func resolve(db *gorm.DB, clinicID, patientID *uint) (Thresholds, error) {
+	thresholds := DefaultThresholds()
+
+	if clinicID != nil {
+		var profile models.ThresholdProfile
+		result := db.Where("clinic_id = ?", *clinicID).Limit(1).Find(&profile)
+		if result.Error != nil {
+			return Thresholds{}, result.Error
+		}
+		if result.RowsAffected > 0 {
+			thresholds.apply(profile)
+		}
+	}
+
+	if patientID != nil {
+		var profile models.ThresholdProfile
+		result := db.Where("patient_id = ?", *patientID).Limit(1).Find(&profile)
+		if result.Error != nil {
+			return Thresholds{}, result.Error
+		}
+		if result.RowsAffected > 0 {
+			thresholds.apply(profile)
+		}
+	}
+
+	return thresholds, nil
+}
+
+// Validate перевіряє межі, отримані після застосування всіх профілів. Профіль може задавати
+// лише одну межу освітленості, тому окремо коректні профілі клініки й пацієнта разом можуть суперечити.
+func (t Thresholds) Validate() error {
+	if t.MinLux >= t.MaxLux {
+		return ErrResolvedLuxRange
+	}
+	return nil
+}
+
+// ConflictingClinicPatients повертає пацієнтів клініки, чий індивідуальний профіль разом із
+// профілем клініки дає суперечливі межі.
+func ConflictingClinicPatients(db *gorm.DB, clinicID uint) ([]uint, error) {
+	clinic, err := ResolveClinicThresholds(db, clinicID)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []models.ThresholdProfile
+	if err := db.Where("patient_id IN (?)", db.Model(&models.User{}).Select("id").Where("clinic_id = ?", clinicID)).
+		Order("patient_id").
+		Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+
+	conflicts := []uint{}
+	for _, profile := range profiles {
+		thresholds := clinic
+		thresholds.apply(profile)
+		if thresholds.Validate() != nil {
+			conflicts = append(conflicts, *profile.PatientID)
+		}
+	}
+	return conflicts, nil
+}
+
+// ValidateThresholdProfile перевіряє значення, задані в профілі.
+func ValidateThresholdProfile(profile models.ThresholdProfile) error {
+	if profile.MaxPostureAngle != nil && !inRange(*profile.MaxPostureAngle, 0, MaxPostureAngle) {
+		return ErrInvalidPostureThreshold
+	}
+	if profile.MinLux != nil && !inRange(*profile.MinLux, MinAmbientLux, MaxAmbientLux) {
+		return ErrInvalidLuxThreshold
+	}
+	if profile.MaxLux != nil && !inRange(*profile.MaxLux, MinAmbientLux, MaxAmbientLux) {
+		return ErrInvalidLuxThreshold
+	}
+	if profile.MinLux != nil && profile.MaxLux != nil && *profile.MinLux >= *profile.MaxLux {
+		return ErrLuxRange
+	}
+	return nil
+}
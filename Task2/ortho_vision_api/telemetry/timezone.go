@@ -0,0 +1,55 @@
+package telemetry
+
+import (
+	"errors"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTimezone — назва не є часовим поясом бази IANA.
+var ErrInvalidTimezone = errors.New("timezone must be an IANA time zone name, for example Europe/Kyiv")
+
+// LoadTimezone завантажує часовий пояс за назвою IANA.
+// "Local" не приймається, бо залежить від налаштувань сервера.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return location, nil
+}
+
+// ResolveLocation повертає часовий пояс пацієнта: власний, інакше пояс його клініки,
+// інакше пояс за замовчуванням. Повертає gorm.ErrRecordNotFound, якщо пацієнта немає.
+func ResolveLocation(db *gorm.DB, patientID uint) (*time.Location, error) {
+	var patient models.User
+	if err := db.Select("id", "clinic_id", "timezone").First(&patient, patientID).Error; err != nil {
+		return nil, err
+	}
+
+	name := patient.Timezone
+	if name == "" && patient.ClinicID != nil {
+		var clinic models.Clinic
+		result := db.Select("id", "timezone").Where("id = ?", *patient.ClinicID).Limit(1).Find(&clinic)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		name = clinic.Timezone
+	}
+	if name == "" {
+		name = config.DefaultTimezone
+	}
+
+	// Пояс перевіряється під час збереження, тому помилка тут означає застарілу базу поясів
+	location, err := LoadTimezone(name)
+	if err != nil {
+		return LoadTimezone(config.DefaultTimezone)
+	}
+	return location, nil
+}
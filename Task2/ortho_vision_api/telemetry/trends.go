@@ -0,0 +1,271 @@
+package telemetry
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dailyTrendSQL підсумовує похвилинні агрегати пацієнта по добах місцевого часу.
+// Кількість хвилин з показниками вважається часом носіння окулярів; доби, коли окуляри
+// носили менше за min_minutes, не враховуються.
+const dailyTrendSQL = `
+SELECT date_trunc('day', bucket_start, @timezone) AS day,
+	COUNT(*) AS minutes,
+	SUM(readings) AS readings,
+	SUM(posture_angle_sum) AS posture_angle_sum,
+	SUM(ambient_lux_sum) AS ambient_lux_sum,
+	SUM(time_head_tilt_exceeded) AS tilt_seconds,
+	SUM(time_low_light) + SUM(time_high_light) AS light_seconds
+FROM %s
+WHERE user_id = @patient_id AND bucket_start >= @from AND bucket_start < @to
+GROUP BY 1
+HAVING COUNT(*) >= @min_minutes
+ORDER BY 1`
+
+// dailyTrend — показники пацієнта за одну добу.
+type dailyTrend struct {
+	Day             time.Time
+	Minutes         int64
+	Readings        int64
+	PostureAngleSum float64
+	AmbientLuxSum   float64
+	TiltSeconds     float64
+	LightSeconds    float64
+}
+
+// tiltShare — частка часу носіння з надмірним нахилом голови.
+func (d dailyTrend) tiltShare() float64 {
+	return math.Min(d.TiltSeconds/float64(d.Minutes*60), 1)
+}
+
+// lightShare — частка часу носіння з освітленням поза межами.
+func (d dailyTrend) lightShare() float64 {
+	return math.Min(d.LightSeconds/float64(d.Minutes*60), 1)
+}
+
+// TrendJob у фоні створює щотижневі звіти для пацієнтів, у яких завершився тиждень
+// (за їхнім місцевим часом) і агрегати за нього вже побудовано.
+type TrendJob struct {
+	db   *gorm.DB
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewTrendJob створює TrendJob. Щоб почати роботу, потрібно викликати Start.
+func NewTrendJob(db *gorm.DB) *TrendJob {
+	return &TrendJob{
+		db:   db,
+		stop: make(chan struct{}),
+	}
+}
+
+// Start запускає перевірку з інтервалом config.TrendInterval.
+func (j *TrendJob) Start() {
+	j.done.Add(1)
+	go func() {
+		defer j.done.Done()
+		ticker := time.NewTicker(config.TrendInterval)
+		defer ticker.Stop()
+		for {
+			j.RunOnce()
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop зупиняє фонову роботу, дочекавшись завершення поточного запуску.
+func (j *TrendJob) Stop() {
+	close(j.stop)
+	j.done.Wait()
+}
+
+// RunOnce створює звіти за останній завершений тиждень для пацієнтів із показниками за цей тиждень.
+func (j *TrendJob) RunOnce() {
+	now := time.Now()
+
+	// Показники останнього завершеного тижня не старші за 15 діб у будь-якому часовому поясі
+	var patientIDs []uint
+	if err := j.db.Model(&models.SmartGlassesMinuteRollup{}).
+		Where("bucket_start >= ?", now.AddDate(0, 0, -15)).
+		Distinct().Pluck("user_id", &patientIDs).Error; err != nil {
+		log.Println("Trend analysis error:", err)
+		return
+	}
+
+	for _, patientID := range patientIDs {
+		if err := j.reportPatient(patientID, now); err != nil {
+			log.Println("Trend analysis error for patient", patientID, ":", err)
+		}
+	}
+}
+
+// reportPatient створює звіт пацієнта за останній завершений тиждень, якщо його ще немає.
+func (j *TrendJob) reportPatient(patientID uint, now time.Time) error {
+	location, err := ResolveLocation(j.db, patientID)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	weekStart := weekStartOf(now.In(location)).AddDate(0, 0, -7)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	var existing int64
+	if err := j.db.Model(&models.WeeklyReport{}).
+		Where("patient_id = ? AND week_start = ?", patientID, weekStart).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	// Чекаємо, доки агрегати за весь тиждень будуть повні
+	validUntil, err := rollupValidUntil(j.db, patientID)
+	if err != nil {
+		return err
+	}
+	if validUntil.Before(weekEnd) {
+		return nil
+	}
+
+	report, err := BuildWeeklyReport(j.db, patientID, weekStart)
+	if err != nil {
+		return err
+	}
+	return j.db.Clauses(clause.OnConflict{DoNothing: true}).Create(report).Error
+}
+
+// BuildWeeklyReport рахує звіт пацієнта за тиждень, що починається з weekStart
+// (понеділок 00:00 у часовому поясі пацієнта), і порівнює його з базовою лінією
+// config.TrendBaselineWeeks попередніх тижнів.
+//
+// Погіршення вважається статистично значущим, якщо середня щоденна частка часу з порушеннями
+// зросла щонайменше на config.TrendMinShareIncrease, а z-статистика різниці середніх
+// (наближення Велча для нормального розподілу) не менша за config.TrendSignificanceZ.
+func BuildWeeklyReport(db *gorm.DB, patientID uint, weekStart time.Time) (*models.WeeklyReport, error) {
+	location := weekStart.Location()
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	baselineStart := weekStart.AddDate(0, 0, -7*config.TrendBaselineWeeks)
+
+	var days []dailyTrend
+	err := db.Raw(fmt.Sprintf(dailyTrendSQL, models.SmartGlassesMinuteRollup{}.TableName()), map[string]interface{}{
+		"patient_id":  patientID,
+		"from":        baselineStart,
+		"to":          weekEnd,
+		"timezone":    location.String(),
+		"min_minutes": config.TrendMinWornMinutes,
+	}).Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.WeeklyReport{
+		PatientID: patientID,
+		WeekStart: weekStart,
+		WeekEnd:   weekEnd,
+		Timezone:  location.String(),
+	}
+
+	var weekTilt, weekLight, baselineTilt, baselineLight []float64
+	var readings int64
+	var postureSum, luxSum float64
+	for _, day := range days {
+		if day.Day.Before(weekStart) {
+			baselineTilt = append(baselineTilt, day.tiltShare())
+			baselineLight = append(baselineLight, day.lightShare())
+			continue
+		}
+		weekTilt = append(weekTilt, day.tiltShare())
+		weekLight = append(weekLight, day.lightShare())
+		report.WornSeconds += float64(day.Minutes * 60)
+		readings += day.Readings
+		postureSum += day.PostureAngleSum
+		luxSum += day.AmbientLuxSum
+	}
+
+	report.DaysWithData = len(weekTilt)
+	report.BaselineDays = len(baselineTilt)
+	if readings > 0 {
+		report.AvgPostureAngle = floatPtr(postureSum / float64(readings))
+		report.AvgAmbientLux = floatPtr(luxSum / float64(readings))
+	}
+	if len(weekTilt) > 0 {
+		report.TiltShare = floatPtr(mean(weekTilt))
+		report.LightShare = floatPtr(mean(weekLight))
+	}
+	if len(baselineTilt) > 0 {
+		report.BaselineTiltShare = floatPtr(mean(baselineTilt))
+		report.BaselineLightShare = floatPtr(mean(baselineLight))
+	}
+
+	if len(weekTilt) >= config.TrendMinReportDays && len(baselineTilt) >= config.TrendMinBaselineDays {
+		report.TiltZScore, report.PostureDeteriorated = compareShares(weekTilt, baselineTilt)
+		report.LightZScore, report.LightingDeteriorated = compareShares(weekLight, baselineLight)
+	}
+	return report, nil
+}
+
+// weekStartOf повертає понеділок 00:00 тижня, що містить t, у часовому поясі t.
+func weekStartOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	return midnight.AddDate(0, 0, -((int(midnight.Weekday()) + 6) % 7))
+}
+
+// compareShares повертає z-статистику зростання середньої частки порівняно з базовою лінією
+// і ознаку значущого погіршення. Якщо розкид в обох вибірках нульовий, z не визначена,
+// і погіршенням вважається саме зростання частки не менше за поріг.
+func compareShares(week, baseline []float64) (*float64, bool) {
+	weekMean, weekVariance := mean(week), variance(week)
+	baselineMean, baselineVariance := mean(baseline), variance(baseline)
+	increase := weekMean - baselineMean
+
+	standardError := math.Sqrt(weekVariance/float64(len(week)) + baselineVariance/float64(len(baseline)))
+	if standardError == 0 {
+		return nil, increase >= config.TrendMinShareIncrease
+	}
+	z := increase / standardError
+	return &z, z >= config.TrendSignificanceZ && increase >= config.TrendMinShareIncrease
+}
+
+// mean — середнє значення вибірки.
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// variance — вибіркова дисперсія (з поправкою Бесселя).
+func variance(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	sum := 0.0
+	for _, value := range values {
+		sum += (value - m) * (value - m)
+	}
+	return sum / float64(len(values)-1)
+}
+
+// floatPtr повертає вказівник на копію значення.
+func floatPtr(value float64) *float64 {
+	return &value
+}
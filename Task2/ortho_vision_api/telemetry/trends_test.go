@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestWeekStartOf(t *testing.T) {
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{"wednesday", time.Date(2026, 1, 7, 15, 30, 0, 0, time.UTC), time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"monday midnight", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"sunday night", time.Date(2026, 1, 11, 23, 59, 59, 0, time.UTC), time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"local monday is still sunday in UTC", time.Date(2026, 1, 4, 23, 30, 0, 0, time.UTC).In(kyiv), time.Date(2026, 1, 5, 0, 0, 0, 0, kyiv)},
+		{"week with daylight saving switch", time.Date(2026, 3, 29, 12, 0, 0, 0, kyiv), time.Date(2026, 3, 23, 0, 0, 0, 0, kyiv)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weekStartOf(tt.t); !got.Equal(tt.want) {
+				t.Fatalf("weekStartOf(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareShares(t *testing.T) {
+	tests := []struct {
+		name     string
+		week     []float64
+		baseline []float64
+		wantZ    bool
+		worse    bool
+	}{
+		{"no change without spread", []float64{0.25, 0.25}, []float64{0.25, 0.25}, false, false},
+		{"increase without spread", []float64{0.25, 0.25}, []float64{0.125, 0.125, 0.125}, false, true},
+		{"small increase without spread", []float64{0.15625, 0.15625}, []float64{0.125, 0.125}, false, false},
+		{"significant increase", []float64{0.30, 0.32, 0.34}, []float64{0.10, 0.12, 0.14}, true, true},
+		{"increase within noise", []float64{0.1, 0.5}, []float64{0.1, 0.3}, true, false},
+		{"significant but small increase", []float64{0.201, 0.202, 0.203}, []float64{0.171, 0.172, 0.173}, true, false},
+		{"decrease", []float64{0.10, 0.12, 0.14}, []float64{0.30, 0.32, 0.34}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, worse := compareShares(tt.week, tt.baseline)
+			if (z != nil) != tt.wantZ {
+				t.Fatalf("z = %v, want defined: %v", z, tt.wantZ)
+			}
+			if worse != tt.worse {
+				t.Fatalf("worse = %v, want %v", worse, tt.worse)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"errors"
+	"math"
+	"ortho_vision_api/config"
+	"ortho_vision_api/models"
+	"time"
+	"unicode/utf8"
+)
+
+// Допустимі межі значень датчиків
+const (
+	MinPostureAngle   = -180.0
+	MaxPostureAngle   = 180.0
+	MinAmbientLux     = 0.0
+	MaxAmbientLux     = 200000.0 // Освітленість у lux; пряме сонячне світло — близько 100000
+	MaxScreenDistance = 1000.0   // см
+	MaxBlinkRate      = 120.0    // кліпань за хвилину
+	MaxBatteryLevel   = 100.0    // %
+	MaxBootIDLength   = 64       // символів boot_id (розмір колонки)
+)
+
+// Помилки перевірки показника
+var (
+	ErrMissingTimestamp   = errors.New("timestamp is required")
+	ErrFutureTimestamp    = errors.New("timestamp is in the future")
+	ErrTimestampTooOld    = errors.New("timestamp is too old")
+	ErrInvalidPosture     = errors.New("posture_angle must be between -180 and 180")
+	ErrInvalidAmbientLux  = errors.New("ambient_lux must be between 0 and 200000")
+	ErrInvalidDistance    = errors.New("screen_distance_cm must be between 0 and 1000")
+	ErrInvalidBlinkRate   = errors.New("blink_rate must be between 0 and 120")
+	ErrInvalidBattery     = errors.New("battery_level must be between 0 and 100")
+	ErrInvalidPayload     = errors.New("reading must be a JSON object")
+	ErrInvalidSequence    = errors.New("sequence must not be negative")
+	ErrInvalidBootID      = errors.New("boot_id must not be longer than 64 characters")
+	ErrBatchEmpty         = errors.New("batch contains no readings")
+	ErrBatchTooLarge      = errors.New("batch contains too many readings")
+	ErrInvalidBatchFormat = errors.New("batch must be a JSON array or NDJSON")
+)
+
+// Validate перевіряє один показник відносно поточного часу сервера now.
+func Validate(reading models.SmartGlassesData, now time.Time) error {
+	if reading.Timestamp.IsZero() {
+		return ErrMissingTimestamp
+	}
+	if reading.Timestamp.After(now.Add(config.TelemetryMaxClockSkew)) {
+		return ErrFutureTimestamp
+	}
+	if reading.Timestamp.Before(now.Add(-config.TelemetryMaxAge)) {
+		return ErrTimestampTooOld
+	}
+	if reading.Sequence != nil && *reading.Sequence < 0 {
+		return ErrInvalidSequence
+	}
+	if utf8.RuneCountInString(reading.BootID) > MaxBootIDLength {
+		return ErrInvalidBootID
+	}
+	if !inRange(reading.PostureAngle, MinPostureAngle, MaxPostureAngle) {
+		return ErrInvalidPosture
+	}
+	if !inRange(reading.AmbientLux, MinAmbientLux, MaxAmbientLux) {
+		return ErrInvalidAmbientLux
+	}
+	// Необов'язкові поля перевіряються лише тоді, коли пристрій їх надіслав
+	if reading.ScreenDistance != nil && !inRange(*reading.ScreenDistance, 0, MaxScreenDistance) {
+		return ErrInvalidDistance
+	}
+	if reading.BlinkRate != nil && !inRange(*reading.BlinkRate, 0, MaxBlinkRate) {
+		return ErrInvalidBlinkRate
+	}
+	if reading.BatteryLevel != nil && !inRange(*reading.BatteryLevel, 0, MaxBatteryLevel) {
+		return ErrInvalidBattery
+	}
+	return nil
+}
+
+// inRange перевіряє, що значення скінченне і лежить у межах [min, max].
+func inRange(value, min, max float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && value >= min && value <= max
+}